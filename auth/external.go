@@ -0,0 +1,185 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"regexp"
+	"time"
+
+	"navdesk/models"
+	"navdesk/storage"
+)
+
+var (
+	// ErrAccessDenied 外部认证通过，但没有匹配的角色或不允许登录该账号
+	ErrAccessDenied = errors.New("当前账号没有访问权限")
+	// ErrAccountDisabled 账号已被禁用
+	ErrAccountDisabled = errors.New("账号已被禁用")
+)
+
+// usernamePattern 用户名格式，secretKey 为 users.json 中的保留键，另行排除
+var usernamePattern = regexp.MustCompile(`^[A-Za-z0-9_.-]{3,32}$`)
+
+// ValidUsername 判断用户名是否可用
+func ValidUsername(username string) bool {
+	return usernamePattern.MatchString(username) && username != "secretKey"
+}
+
+// ProvisionUser 外部认证（单点登录、LDAP、反向代理）通过后查找同名账号，需要时自动创建或同步角色。
+// role 为映射后的角色，为空表示没有访问权限；linkExisting 表示是否允许登录同名的其他来源账号（如本地账号）
+func ProvisionUser(store storage.Store, provider, username, role string, autoCreate, linkExisting bool) (*models.User, error) {
+	if err := store.Lock(); err != nil {
+		return nil, err
+	}
+	defer store.Unlock()
+
+	users, err := store.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	user, exists := users[username]
+	switch {
+	case !exists:
+		if !autoCreate {
+			log.Printf("%s 登录失败: 用户 %s 不存在，且未开启自动创建", provider, username)
+			return nil, ErrAccessDenied
+		}
+		if !ValidUsername(username) {
+			return nil, fmt.Errorf("用户名 %q 不符合要求", username)
+		}
+		if role == "" {
+			log.Printf("%s 登录失败: 用户 %s 没有匹配的角色", provider, username)
+			return nil, ErrAccessDenied
+		}
+		user = models.User{
+			Username:  username,
+			Role:      role,
+			Provider:  provider,
+			CreatedAt: time.Now(),
+		}
+		log.Printf("%s 登录自动创建用户: %s (%s)", provider, username, role)
+	case user.Disabled:
+		log.Printf("%s 登录失败: 用户名 %s - 账号已被禁用", provider, username)
+		return nil, ErrAccountDisabled
+	case user.Provider == provider:
+		// 外部账号的角色以外部映射为准，本地账号的角色仍由管理员维护
+		if role == "" {
+			log.Printf("%s 登录失败: 用户 %s 没有匹配的角色", provider, username)
+			return nil, ErrAccessDenied
+		}
+		if role == user.Role {
+			return &user, nil
+		}
+		log.Printf("%s 登录同步角色: %s %s -> %s", provider, username, user.Role, role)
+		user.Role = role
+		user.UpdatedAt = time.Now()
+	case !linkExisting:
+		log.Printf("%s 登录失败: 用户 %s 已存在且不是 %s 账号", provider, username, provider)
+		return nil, ErrAccessDenied
+	default:
+		return &user, nil
+	}
+
+	users[username] = user
+	if err := store.SaveUsers(users); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// ProvisionOIDCUser 单点登录通过后按 ID Token 的 iss 与 sub 查找已关联的账号，需要时自动创建或同步角色。
+// 用户名声明可以在身份提供方修改，只在首次登录时用于创建账号或关联已有账号：
+// 已关联其他身份的账号不会被再次关联，尚未关联身份的已有账号只有 linkExisting 为 true 时才会关联。
+// role 为映射后的角色，为空表示没有访问权限，此时既不登录也不关联
+func ProvisionOIDCUser(store storage.Store, issuer, subject, username, role string, autoCreate, linkExisting bool) (*models.User, error) {
+	if issuer == "" || subject == "" {
+		return nil, fmt.Errorf("ID Token 缺少 iss 或 sub 声明")
+	}
+
+	if err := store.Lock(); err != nil {
+		return nil, err
+	}
+	defer store.Unlock()
+
+	users, err := store.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+
+	// 已关联该身份的账号
+	for key, user := range users {
+		if user.ExternalIssuer != issuer || user.ExternalSubject != subject {
+			continue
+		}
+		if user.Disabled {
+			log.Printf("%s 登录失败: 用户名 %s - 账号已被禁用", models.ProviderOIDC, user.Username)
+			return nil, ErrAccountDisabled
+		}
+		if role == "" {
+			log.Printf("%s 登录失败: 用户 %s 没有匹配的角色", models.ProviderOIDC, user.Username)
+			return nil, ErrAccessDenied
+		}
+		// 外部账号的角色以外部映射为准，关联的本地账号的角色仍由管理员维护
+		if user.Provider != models.ProviderOIDC || role == user.Role {
+			return &user, nil
+		}
+		log.Printf("%s 登录同步角色: %s %s -> %s", models.ProviderOIDC, user.Username, user.Role, role)
+		user.Role = role
+		user.UpdatedAt = time.Now()
+		users[key] = user
+		if err := store.SaveUsers(users); err != nil {
+			return nil, err
+		}
+		return &user, nil
+	}
+
+	user, exists := users[username]
+	switch {
+	case !exists:
+		if !autoCreate {
+			log.Printf("%s 登录失败: 用户 %s 不存在，且未开启自动创建", models.ProviderOIDC, username)
+			return nil, ErrAccessDenied
+		}
+		if !ValidUsername(username) {
+			return nil, fmt.Errorf("用户名 %q 不符合要求", username)
+		}
+		if role == "" {
+			log.Printf("%s 登录失败: 用户 %s 没有匹配的角色", models.ProviderOIDC, username)
+			return nil, ErrAccessDenied
+		}
+		user = models.User{
+			Username:        username,
+			Role:            role,
+			Provider:        models.ProviderOIDC,
+			ExternalIssuer:  issuer,
+			ExternalSubject: subject,
+			CreatedAt:       time.Now(),
+		}
+		log.Printf("%s 登录自动创建用户: %s (%s)", models.ProviderOIDC, username, role)
+	case user.ExternalSubject != "":
+		log.Printf("%s 登录失败: 用户 %s 已关联其他单点登录身份 (sub=%s)", models.ProviderOIDC, username, subject)
+		return nil, ErrAccessDenied
+	case user.Disabled:
+		log.Printf("%s 登录失败: 用户名 %s - 账号已被禁用", models.ProviderOIDC, username)
+		return nil, ErrAccountDisabled
+	case role == "":
+		log.Printf("%s 登录失败: 用户 %s 没有匹配的角色", models.ProviderOIDC, username)
+		return nil, ErrAccessDenied
+	case !linkExisting:
+		log.Printf("%s 登录失败: 用户 %s 已存在，且未开启关联已有账号", models.ProviderOIDC, username)
+		return nil, ErrAccessDenied
+	default:
+		log.Printf("%s 登录关联已有账号: %s (sub=%s)", models.ProviderOIDC, username, subject)
+		user.ExternalIssuer = issuer
+		user.ExternalSubject = subject
+		user.UpdatedAt = time.Now()
+	}
+
+	users[username] = user
+	if err := store.SaveUsers(users); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
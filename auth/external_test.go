@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"navdesk/models"
+	"navdesk/storage"
+)
+
+const testIssuer = "https://idp.example.com"
+
+func newUserStore(t *testing.T, users ...models.User) storage.Store {
+	t.Helper()
+	store := storage.NewJSONStore(t.TempDir())
+	saved := make(map[string]models.User)
+	for _, user := range users {
+		saved[user.Username] = user
+	}
+	if err := store.SaveUsers(saved); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestProvisionOIDCUserDoesNotLinkLocalAccountByDefault(t *testing.T) {
+	store := newUserStore(t, models.User{Username: "admin", Role: models.RoleAdmin})
+
+	if _, err := ProvisionOIDCUser(store, testIssuer, "attacker", "admin", models.RoleAdmin, true, false); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("err = %v，want ErrAccessDenied", err)
+	}
+	users, _ := store.GetUsers()
+	if users["admin"].ExternalSubject != "" {
+		t.Fatalf("本地账号被关联: %+v", users["admin"])
+	}
+}
+
+func TestProvisionOIDCUserLinkRequiresRole(t *testing.T) {
+	store := newUserStore(t, models.User{Username: "admin", Role: models.RoleAdmin})
+
+	if _, err := ProvisionOIDCUser(store, testIssuer, "sub-1", "admin", "", true, true); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("err = %v，want ErrAccessDenied", err)
+	}
+	users, _ := store.GetUsers()
+	if users["admin"].ExternalSubject != "" {
+		t.Fatalf("没有映射角色时关联了账号: %+v", users["admin"])
+	}
+}
+
+func TestProvisionOIDCUserMatchesBySubject(t *testing.T) {
+	store := newUserStore(t, models.User{Username: "admin", Role: models.RoleAdmin})
+
+	// 开启关联后首次登录按用户名关联，本地账号的角色保持不变
+	user, err := ProvisionOIDCUser(store, testIssuer, "sub-1", "admin", models.RoleViewer, true, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "admin" || user.Role != models.RoleAdmin || user.Provider != "" {
+		t.Fatalf("关联后的账号 %+v", user)
+	}
+
+	// 之后在身份提供方改名，仍按 iss 与 sub 找到原账号
+	user, err = ProvisionOIDCUser(store, testIssuer, "sub-1", "renamed", models.RoleViewer, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Username != "admin" {
+		t.Fatalf("按 sub 登录到了 %s", user.Username)
+	}
+
+	// 其他身份使用同一用户名不能登录或再次关联
+	if _, err := ProvisionOIDCUser(store, testIssuer, "sub-2", "admin", models.RoleAdmin, true, true); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("err = %v，want ErrAccessDenied", err)
+	}
+	// 同一 sub 但签发方不同视为不同身份
+	if _, err := ProvisionOIDCUser(store, "https://other.example.com", "sub-1", "admin", models.RoleAdmin, true, true); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("err = %v，want ErrAccessDenied", err)
+	}
+}
+
+func TestProvisionOIDCUserCreatesAndSyncsRole(t *testing.T) {
+	store := newUserStore(t)
+
+	user, err := ProvisionOIDCUser(store, testIssuer, "sub-1", "alice", models.RoleEditor, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Provider != models.ProviderOIDC || user.ExternalIssuer != testIssuer || user.ExternalSubject != "sub-1" {
+		t.Fatalf("自动创建的账号 %+v", user)
+	}
+
+	user, err = ProvisionOIDCUser(store, testIssuer, "sub-1", "alice", models.RoleViewer, true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.Role != models.RoleViewer {
+		t.Fatalf("角色未同步: %s", user.Role)
+	}
+
+	if _, err := ProvisionOIDCUser(store, testIssuer, "sub-1", "alice", "", true, false); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("没有匹配的角色时 err = %v，want ErrAccessDenied", err)
+	}
+}
+
+func TestProvisionOIDCUserRejectsDisabledAccount(t *testing.T) {
+	store := newUserStore(t, models.User{
+		Username:        "alice",
+		Role:            models.RoleEditor,
+		Provider:        models.ProviderOIDC,
+		ExternalIssuer:  testIssuer,
+		ExternalSubject: "sub-1",
+		Disabled:        true,
+	})
+
+	if _, err := ProvisionOIDCUser(store, testIssuer, "sub-1", "alice", models.RoleEditor, true, false); !errors.Is(err, ErrAccountDisabled) {
+		t.Fatalf("err = %v，want ErrAccountDisabled", err)
+	}
+}
+
+func TestProvisionOIDCUserDoesNotAdoptByUsername(t *testing.T) {
+	// 没有记录 sub 的单点登录账号同样不能仅凭用户名接管
+	store := newUserStore(t, models.User{Username: "alice", Role: models.RoleEditor, Provider: models.ProviderOIDC})
+
+	if _, err := ProvisionOIDCUser(store, testIssuer, "sub-1", "alice", models.RoleEditor, true, false); !errors.Is(err, ErrAccessDenied) {
+		t.Fatalf("err = %v，want ErrAccessDenied", err)
+	}
+	users, _ := store.GetUsers()
+	if users["alice"].ExternalSubject != "" {
+		t.Fatalf("按用户名关联了账号: %+v", users["alice"])
+	}
+}
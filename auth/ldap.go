@@ -0,0 +1,183 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+var (
+	// ErrLDAPInvalidCredentials 用户不存在或密码错误
+	ErrLDAPInvalidCredentials = errors.New("LDAP 账号或密码错误")
+)
+
+// LDAPConfig LDAP / Active Directory 认证配置
+type LDAPConfig struct {
+	// URL 服务器地址，ldaps:// 直接使用 TLS，ldap:// 默认通过 StartTLS 升级
+	URL string
+	// StartTLS 为 false 时 ldap:// 使用明文连接，仅用于本机测试
+	StartTLS bool
+	// TLS 证书校验配置，为空时使用系统根证书
+	TLS *tls.Config
+
+	// BindDN 与 BindPassword 为查询用户所用的服务账号，为空时匿名查询
+	BindDN       string
+	BindPassword string
+
+	// BaseDN 用户查询的起点
+	BaseDN string
+	// UserFilter 用户查询过滤器，%s 替换为转义后的用户名，如 (uid=%s)、(sAMAccountName=%s)
+	UserFilter string
+	// UsernameAttribute 作为 navdesk 用户名的属性，统一用户名大小写
+	UsernameAttribute string
+	// GroupAttribute 用户所属组的属性，如 memberOf
+	GroupAttribute string
+
+	Timeout time.Duration
+}
+
+// LDAPIdentity LDAP 认证通过的用户
+type LDAPIdentity struct {
+	Username string
+	DN       string
+	// Groups 所属组的名称（组 DN 的第一个 RDN 值，如 cn=navdesk-admins,ou=groups 中的 navdesk-admins）
+	Groups []string
+}
+
+// ldapConn 认证过程用到的 LDAP 连接操作，*ldap.Conn 实现该接口，测试中替换为模拟连接
+type ldapConn interface {
+	StartTLS(config *tls.Config) error
+	Bind(username, password string) error
+	Search(request *ldap.SearchRequest) (*ldap.SearchResult, error)
+	SetTimeout(timeout time.Duration)
+	Close() error
+}
+
+// ldapDialer 按配置建立到 LDAP 服务器的连接（不含 StartTLS）
+type ldapDialer func(config LDAPConfig) (ldapConn, error)
+
+// LDAPAuthenticator 通过 LDAP 绑定校验用户名密码
+type LDAPAuthenticator struct {
+	config LDAPConfig
+	dialer ldapDialer
+}
+
+// NewLDAPAuthenticator 创建 LDAP 认证器，不会立即连接服务器
+func NewLDAPAuthenticator(config LDAPConfig) (*LDAPAuthenticator, error) {
+	u, err := url.Parse(config.URL)
+	if err != nil || (u.Scheme != "ldap" && u.Scheme != "ldaps") || u.Host == "" {
+		return nil, fmt.Errorf("无效的 LDAP 地址: %s", config.URL)
+	}
+	if !strings.Contains(config.UserFilter, "%s") {
+		return nil, fmt.Errorf("用户查询过滤器需包含 %%s: %s", config.UserFilter)
+	}
+	if config.TLS == nil {
+		config.TLS = &tls.Config{}
+	}
+	if config.TLS.ServerName == "" {
+		config.TLS.ServerName = u.Hostname()
+	}
+	if config.Timeout <= 0 {
+		config.Timeout = 10 * time.Second
+	}
+	return &LDAPAuthenticator{config: config, dialer: dialLDAP}, nil
+}
+
+// Authenticate 先用服务账号查找用户 DN，再以该 DN 和密码绑定。
+// 用户不存在或密码错误时返回 ErrLDAPInvalidCredentials，其他错误表示服务器不可用或配置错误
+func (a *LDAPAuthenticator) Authenticate(username, password string) (*LDAPIdentity, error) {
+	// 空密码会被服务器当作匿名绑定而“成功”，必须提前拒绝
+	if username == "" || password == "" {
+		return nil, ErrLDAPInvalidCredentials
+	}
+
+	conn, err := a.dial()
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	if a.config.BindDN != "" {
+		if err := conn.Bind(a.config.BindDN, a.config.BindPassword); err != nil {
+			return nil, fmt.Errorf("服务账号绑定失败: %v", err)
+		}
+	}
+
+	attributes := []string{"dn", a.config.UsernameAttribute, a.config.GroupAttribute}
+	result, err := conn.Search(ldap.NewSearchRequest(
+		a.config.BaseDN,
+		ldap.ScopeWholeSubtree, ldap.NeverDerefAliases,
+		2, int(a.config.Timeout.Seconds()), false,
+		fmt.Sprintf(a.config.UserFilter, ldap.EscapeFilter(username)),
+		attributes,
+		nil,
+	))
+	if err != nil && !ldap.IsErrorWithCode(err, ldap.LDAPResultSizeLimitExceeded) {
+		return nil, fmt.Errorf("查询用户失败: %v", err)
+	}
+	if result == nil || len(result.Entries) != 1 {
+		return nil, ErrLDAPInvalidCredentials
+	}
+	entry := result.Entries[0]
+
+	if err := conn.Bind(entry.DN, password); err != nil {
+		if ldap.IsErrorWithCode(err, ldap.LDAPResultInvalidCredentials) {
+			return nil, ErrLDAPInvalidCredentials
+		}
+		return nil, fmt.Errorf("用户绑定失败: %v", err)
+	}
+
+	identity := &LDAPIdentity{
+		Username: entry.GetAttributeValue(a.config.UsernameAttribute),
+		DN:       entry.DN,
+	}
+	if identity.Username == "" {
+		identity.Username = username
+	}
+	for _, group := range entry.GetAttributeValues(a.config.GroupAttribute) {
+		identity.Groups = append(identity.Groups, groupName(group))
+	}
+	return identity, nil
+}
+
+// dial 建立连接，ldap:// 且开启 StartTLS 时在绑定前升级为 TLS
+func (a *LDAPAuthenticator) dial() (ldapConn, error) {
+	conn, err := a.dialer(a.config)
+	if err != nil {
+		return nil, fmt.Errorf("连接 LDAP 服务器失败: %v", err)
+	}
+	conn.SetTimeout(a.config.Timeout)
+
+	if strings.HasPrefix(a.config.URL, "ldap://") && a.config.StartTLS {
+		if err := conn.StartTLS(a.config.TLS); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("StartTLS 失败: %v", err)
+		}
+	}
+	return conn, nil
+}
+
+// dialLDAP 连接 LDAP 服务器，ldaps:// 直接建立 TLS 连接
+func dialLDAP(config LDAPConfig) (ldapConn, error) {
+	dialer := &net.Dialer{Timeout: config.Timeout}
+	conn, err := ldap.DialURL(config.URL, ldap.DialWithDialer(dialer), ldap.DialWithTLSConfig(config.TLS))
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
+// groupName 返回组 DN 第一个 RDN 的值，无法解析为 DN 时原样返回
+func groupName(group string) string {
+	dn, err := ldap.ParseDN(group)
+	if err != nil || len(dn.RDNs) == 0 || len(dn.RDNs[0].Attributes) == 0 {
+		return group
+	}
+	return dn.RDNs[0].Attributes[0].Value
+}
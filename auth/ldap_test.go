@@ -0,0 +1,258 @@
+package auth
+
+import (
+	"crypto/tls"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-ldap/ldap/v3"
+)
+
+// fakeLDAPConn 模拟 LDAP 连接：passwords 为 DN 到密码的映射，entries 为查询结果
+type fakeLDAPConn struct {
+	passwords map[string]string
+	entries   []*ldap.Entry
+	searchErr error
+	tlsErr    error
+
+	startTLS *tls.Config
+	binds    []string
+	filter   string
+	closed   bool
+}
+
+func (f *fakeLDAPConn) StartTLS(config *tls.Config) error {
+	f.startTLS = config
+	return f.tlsErr
+}
+
+func (f *fakeLDAPConn) Bind(username, password string) error {
+	f.binds = append(f.binds, username)
+	if expected, ok := f.passwords[username]; !ok || expected != password {
+		return ldap.NewError(ldap.LDAPResultInvalidCredentials, errors.New("invalid credentials"))
+	}
+	return nil
+}
+
+func (f *fakeLDAPConn) Search(request *ldap.SearchRequest) (*ldap.SearchResult, error) {
+	f.filter = request.Filter
+	return &ldap.SearchResult{Entries: f.entries}, f.searchErr
+}
+
+func (f *fakeLDAPConn) SetTimeout(time.Duration) {}
+
+func (f *fakeLDAPConn) Close() error {
+	f.closed = true
+	return nil
+}
+
+const (
+	testServiceDN = "cn=navdesk,ou=services,dc=example,dc=com"
+	testAliceDN   = "uid=alice,ou=people,dc=example,dc=com"
+)
+
+func aliceEntry() *ldap.Entry {
+	return ldap.NewEntry(testAliceDN, map[string][]string{
+		"uid":      {"alice"},
+		"memberOf": {"cn=navdesk-editors,ou=groups,dc=example,dc=com", "cn=staff,ou=groups,dc=example,dc=com"},
+	})
+}
+
+func newFakeLDAP(t *testing.T, config LDAPConfig, conn *fakeLDAPConn) *LDAPAuthenticator {
+	t.Helper()
+	if config.URL == "" {
+		config.URL = "ldap://ldap.example.com"
+		config.StartTLS = true
+	}
+	config.BindDN = testServiceDN
+	config.BindPassword = "service-secret"
+	config.BaseDN = "dc=example,dc=com"
+	config.UserFilter = "(uid=%s)"
+	config.UsernameAttribute = "uid"
+	config.GroupAttribute = "memberOf"
+
+	authenticator, err := NewLDAPAuthenticator(config)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if conn.passwords == nil {
+		conn.passwords = map[string]string{testServiceDN: "service-secret", testAliceDN: "alice-secret"}
+	}
+	authenticator.dialer = func(LDAPConfig) (ldapConn, error) { return conn, nil }
+	return authenticator
+}
+
+func TestLDAPAuthenticate(t *testing.T) {
+	conn := &fakeLDAPConn{entries: []*ldap.Entry{aliceEntry()}}
+	authenticator := newFakeLDAP(t, LDAPConfig{}, conn)
+
+	identity, err := authenticator.Authenticate("alice", "alice-secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity.Username != "alice" || identity.DN != testAliceDN {
+		t.Fatalf("identity = %+v", identity)
+	}
+	if strings.Join(identity.Groups, ",") != "navdesk-editors,staff" {
+		t.Fatalf("Groups = %v", identity.Groups)
+	}
+	if strings.Join(conn.binds, ";") != testServiceDN+";"+testAliceDN {
+		t.Fatalf("绑定顺序 %v，want 先服务账号后用户", conn.binds)
+	}
+	if !conn.closed {
+		t.Fatal("连接未关闭")
+	}
+
+	// 按所属组映射角色
+	roleMap, err := ParseRoleMap("navdesk-admins=admin,navdesk-editors=editor,staff=viewer")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if role := MapRole(identity.Groups, roleMap); role != "editor" {
+		t.Fatalf("MapRole = %q，want editor", role)
+	}
+}
+
+func TestLDAPAuthenticateFailures(t *testing.T) {
+	second := ldap.NewEntry("uid=alice,ou=contractors,dc=example,dc=com", map[string][]string{"uid": {"alice"}})
+
+	tests := []struct {
+		name     string
+		conn     *fakeLDAPConn
+		password string
+		invalid  bool
+	}{
+		{
+			name:     "密码错误",
+			conn:     &fakeLDAPConn{entries: []*ldap.Entry{aliceEntry()}},
+			password: "wrong",
+			invalid:  true,
+		},
+		{
+			name:     "用户不存在",
+			conn:     &fakeLDAPConn{},
+			password: "alice-secret",
+			invalid:  true,
+		},
+		{
+			name:     "查询到多个用户",
+			conn:     &fakeLDAPConn{entries: []*ldap.Entry{aliceEntry(), second}},
+			password: "alice-secret",
+			invalid:  true,
+		},
+		{
+			name: "查询结果超过数量限制",
+			conn: &fakeLDAPConn{
+				entries:   []*ldap.Entry{aliceEntry(), second},
+				searchErr: ldap.NewError(ldap.LDAPResultSizeLimitExceeded, errors.New("size limit exceeded")),
+			},
+			password: "alice-secret",
+			invalid:  true,
+		},
+		{
+			name:     "空密码",
+			conn:     &fakeLDAPConn{entries: []*ldap.Entry{aliceEntry()}},
+			password: "",
+			invalid:  true,
+		},
+		{
+			name: "服务账号绑定失败",
+			conn: &fakeLDAPConn{
+				entries:   []*ldap.Entry{aliceEntry()},
+				passwords: map[string]string{testServiceDN: "rotated", testAliceDN: "alice-secret"},
+			},
+			password: "alice-secret",
+		},
+		{
+			name: "查询失败",
+			conn: &fakeLDAPConn{
+				searchErr: ldap.NewError(ldap.LDAPResultBusy, errors.New("busy")),
+			},
+			password: "alice-secret",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			authenticator := newFakeLDAP(t, LDAPConfig{}, tt.conn)
+			identity, err := authenticator.Authenticate("alice", tt.password)
+			if identity != nil || err == nil {
+				t.Fatalf("Authenticate = %+v, %v; want 错误", identity, err)
+			}
+			if got := errors.Is(err, ErrLDAPInvalidCredentials); got != tt.invalid {
+				t.Fatalf("err = %v，是否为 ErrLDAPInvalidCredentials: %v，want %v", err, got, tt.invalid)
+			}
+		})
+	}
+}
+
+func TestLDAPAuthenticateEscapesFilter(t *testing.T) {
+	conn := &fakeLDAPConn{}
+	authenticator := newFakeLDAP(t, LDAPConfig{}, conn)
+
+	if _, err := authenticator.Authenticate("*)(uid=*", "x"); !errors.Is(err, ErrLDAPInvalidCredentials) {
+		t.Fatalf("err = %v", err)
+	}
+	if conn.filter != `(uid=\2a\29\28uid=\2a)` {
+		t.Fatalf("filter = %s", conn.filter)
+	}
+}
+
+func TestLDAPStartTLS(t *testing.T) {
+	tests := []struct {
+		name     string
+		url      string
+		startTLS bool
+		want     bool
+	}{
+		{name: "ldap 默认升级", url: "ldap://ldap.example.com:389", startTLS: true, want: true},
+		{name: "ldap 明文", url: "ldap://ldap.example.com:389", startTLS: false, want: false},
+		{name: "ldaps 不再升级", url: "ldaps://ldap.example.com:636", startTLS: true, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			conn := &fakeLDAPConn{entries: []*ldap.Entry{aliceEntry()}}
+			authenticator := newFakeLDAP(t, LDAPConfig{URL: tt.url, StartTLS: tt.startTLS}, conn)
+			if _, err := authenticator.Authenticate("alice", "alice-secret"); err != nil {
+				t.Fatal(err)
+			}
+			if got := conn.startTLS != nil; got != tt.want {
+				t.Fatalf("StartTLS 调用 %v，want %v", got, tt.want)
+			}
+			if conn.startTLS != nil && conn.startTLS.ServerName != "ldap.example.com" {
+				t.Fatalf("ServerName = %q", conn.startTLS.ServerName)
+			}
+		})
+	}
+}
+
+func TestLDAPStartTLSFailure(t *testing.T) {
+	conn := &fakeLDAPConn{entries: []*ldap.Entry{aliceEntry()}, tlsErr: errors.New("handshake failure")}
+	authenticator := newFakeLDAP(t, LDAPConfig{}, conn)
+
+	_, err := authenticator.Authenticate("alice", "alice-secret")
+	if err == nil || errors.Is(err, ErrLDAPInvalidCredentials) || !strings.Contains(err.Error(), "StartTLS") {
+		t.Fatalf("err = %v，want StartTLS 失败", err)
+	}
+	if len(conn.binds) != 0 {
+		t.Fatalf("StartTLS 失败后仍发送了密码: %v", conn.binds)
+	}
+	if !conn.closed {
+		t.Fatal("连接未关闭")
+	}
+}
+
+func TestNewLDAPAuthenticatorValidatesConfig(t *testing.T) {
+	for _, config := range []LDAPConfig{
+		{URL: "http://ldap.example.com", UserFilter: "(uid=%s)"},
+		{URL: "ldap://", UserFilter: "(uid=%s)"},
+		{URL: "ldap://ldap.example.com", UserFilter: "(uid=alice)"},
+	} {
+		if _, err := NewLDAPAuthenticator(config); err == nil {
+			t.Errorf("NewLDAPAuthenticator(%+v) 未返回错误", config)
+		}
+	}
+}
@@ -0,0 +1,276 @@
+package auth
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testClientID    = "navdesk"
+	testRedirectURL = "https://nav.example.com/api/auth/oidc/callback"
+)
+
+// mockIdP 模拟身份提供方：提供 discovery、JWKS 与令牌端点，令牌端点校验 PKCE
+type mockIdP struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+
+	mu sync.Mutex
+	// codes 授权码对应的 code_challenge 与 nonce
+	codes map[string][2]string
+	// signingKey 签发 ID Token 使用的密钥，为空时使用 key
+	signingKey *rsa.PrivateKey
+	// overrides 覆盖 ID Token 中的声明，值为 nil 表示删除该声明
+	overrides map[string]interface{}
+	// discoveryIssuer 覆盖 discovery 中的 issuer
+	discoveryIssuer string
+}
+
+func newMockIdP(t *testing.T) *mockIdP {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := &mockIdP{key: key, codes: make(map[string][2]string)}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+		issuer := m.server.URL
+		if m.discoveryIssuer != "" {
+			issuer = m.discoveryIssuer
+		}
+		json.NewEncoder(w).Encode(map[string]string{
+			"issuer":                 issuer,
+			"authorization_endpoint": m.server.URL + "/authorize",
+			"token_endpoint":         m.server.URL + "/token",
+			"jwks_uri":               m.server.URL + "/jwks",
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"keys": []map[string]string{{
+				"kid": "k1",
+				"kty": "RSA",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(m.key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(m.key.E)).Bytes()),
+			}},
+		})
+	})
+	mux.HandleFunc("/token", m.token)
+	m.server = httptest.NewServer(mux)
+	t.Cleanup(m.server.Close)
+	return m
+}
+
+// authorize 模拟用户在身份提供方登录，返回回调中的授权码
+func (m *mockIdP) authorize(t *testing.T, authURL string) string {
+	t.Helper()
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	query := u.Query()
+	if query.Get("code_challenge_method") != "S256" || query.Get("client_id") != testClientID {
+		t.Fatalf("授权地址参数错误: %s", authURL)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	code := "code-" + query.Get("state")
+	m.codes[code] = [2]string{query.Get("code_challenge"), query.Get("nonce")}
+	return code
+}
+
+func (m *mockIdP) token(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	m.mu.Lock()
+	entry, ok := m.codes[r.Form.Get("code")]
+	delete(m.codes, r.Form.Get("code"))
+	m.mu.Unlock()
+
+	sum := sha256.Sum256([]byte(r.Form.Get("code_verifier")))
+	if !ok || base64.RawURLEncoding.EncodeToString(sum[:]) != entry[0] {
+		w.WriteHeader(http.StatusBadRequest)
+		json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	now := time.Now()
+	claims := map[string]interface{}{
+		"iss":                m.server.URL,
+		"aud":                testClientID,
+		"sub":                "user-1",
+		"preferred_username": "alice",
+		"nonce":              entry[1],
+		"iat":                now.Unix(),
+		"exp":                now.Add(5 * time.Minute).Unix(),
+	}
+	for name, value := range m.overrides {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+
+	key := m.key
+	if m.signingKey != nil {
+		key = m.signingKey
+	}
+	json.NewEncoder(w).Encode(map[string]string{"id_token": signIDToken(key, claims)})
+}
+
+func signIDToken(key *rsa.PrivateKey, claims map[string]interface{}) string {
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "kid": "k1", "typ": "JWT"})
+	payload, _ := json.Marshal(claims)
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		panic(err)
+	}
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+// login 走完一次授权码流程，返回 Exchange 的结果
+func (m *mockIdP) login(t *testing.T, provider *OIDCProvider, modify func(req *OIDCRequest)) (map[string]interface{}, error) {
+	t.Helper()
+	req, err := NewOIDCRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := provider.AuthCodeURL(context.Background(), req, testRedirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code := m.authorize(t, authURL)
+	if modify != nil {
+		modify(&req)
+	}
+	return provider.Exchange(context.Background(), code, req, testRedirectURL)
+}
+
+func (m *mockIdP) provider() *OIDCProvider {
+	return NewOIDCProvider(OIDCConfig{Issuer: m.server.URL, ClientID: testClientID})
+}
+
+func TestOIDCExchange(t *testing.T) {
+	idp := newMockIdP(t)
+	claims, err := idp.login(t, idp.provider(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims["sub"] != "user-1" || claims["iss"] != idp.server.URL {
+		t.Fatalf("声明错误: %v", claims)
+	}
+}
+
+func TestOIDCExchangeRejectsInvalidToken(t *testing.T) {
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		signingKey *rsa.PrivateKey
+		overrides  map[string]interface{}
+		modify     func(req *OIDCRequest)
+		want       string
+	}{
+		{name: "签名无效", signingKey: otherKey, want: "签名无效"},
+		{name: "受众不匹配", overrides: map[string]interface{}{"aud": "other-client"}, want: "受众"},
+		{name: "受众数组不含客户端", overrides: map[string]interface{}{"aud": []string{"a", "b"}}, want: "受众"},
+		{name: "azp 不匹配", overrides: map[string]interface{}{"azp": "other-client"}, want: "azp"},
+		{name: "签发方不匹配", overrides: map[string]interface{}{"iss": "https://evil.example.com"}, want: "签发方"},
+		{name: "已过期", overrides: map[string]interface{}{"exp": time.Now().Add(-time.Hour).Unix()}, want: "过期"},
+		{name: "缺少过期时间", overrides: map[string]interface{}{"exp": nil}, want: "过期"},
+		{name: "签发时间在未来", overrides: map[string]interface{}{"iat": time.Now().Add(time.Hour).Unix()}, want: "签发时间"},
+		{name: "缺少 sub", overrides: map[string]interface{}{"sub": nil}, want: "sub"},
+		{name: "nonce 不匹配", modify: func(req *OIDCRequest) { req.Nonce = "other-nonce" }, want: "nonce"},
+		{name: "PKCE verifier 不匹配", modify: func(req *OIDCRequest) { req.CodeVerifier = "wrong-verifier" }, want: "invalid_grant"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			idp := newMockIdP(t)
+			idp.signingKey = tt.signingKey
+			idp.overrides = tt.overrides
+
+			claims, err := idp.login(t, idp.provider(), tt.modify)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Fatalf("Exchange = %v, %v; want 包含 %q 的错误", claims, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOIDCDiscoveryIssuerMismatch(t *testing.T) {
+	idp := newMockIdP(t)
+	idp.discoveryIssuer = "https://evil.example.com"
+
+	req, err := NewOIDCRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := idp.provider().AuthCodeURL(context.Background(), req, testRedirectURL); err == nil || !strings.Contains(err.Error(), "issuer") {
+		t.Fatalf("AuthCodeURL 错误 = %v，want issuer 不匹配", err)
+	}
+}
+
+func TestOIDCAuthCodeURLUsesPKCE(t *testing.T) {
+	idp := newMockIdP(t)
+	req, err := NewOIDCRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authURL, err := idp.provider().AuthCodeURL(context.Background(), req, testRedirectURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(authURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	query := u.Query()
+	sum := sha256.Sum256([]byte(req.CodeVerifier))
+	if query.Get("code_challenge") != base64.RawURLEncoding.EncodeToString(sum[:]) {
+		t.Fatalf("code_challenge 不是 code_verifier 的 S256 摘要")
+	}
+	if query.Get("state") != req.State || query.Get("nonce") != req.Nonce {
+		t.Fatalf("授权地址缺少 state 或 nonce: %s", authURL)
+	}
+	if strings.Contains(authURL, req.CodeVerifier) {
+		t.Fatalf("授权地址泄露了 code_verifier")
+	}
+}
+
+func TestNewOIDCRequestIsRandom(t *testing.T) {
+	a, err := NewOIDCRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, err := NewOIDCRequest()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if a.State == b.State || a.Nonce == b.Nonce || a.CodeVerifier == b.CodeVerifier || a.State == a.Nonce {
+		t.Fatalf("登录参数重复: %+v %+v", a, b)
+	}
+}
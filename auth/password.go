@@ -0,0 +1,89 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"log"
+	"strings"
+
+	"navdesk/storage"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// PasswordCost bcrypt 计算强度，低于该强度的已有哈希会在下次登录时重新计算
+const PasswordCost = bcrypt.DefaultCost
+
+// dummyHash 用户不存在时参与比较的哈希，使响应时间与用户存在时一致
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("navdesk-dummy-password"), PasswordCost)
+
+// HashPassword 计算密码的 bcrypt 哈希
+func HashPassword(password string) (string, error) {
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), PasswordCost)
+	if err != nil {
+		return "", err
+	}
+	return string(hash), nil
+}
+
+// IsHashed 判断保存的密码是否已是 bcrypt 哈希
+func IsHashed(stored string) bool {
+	if len(stored) != 60 {
+		return false
+	}
+	return strings.HasPrefix(stored, "$2a$") || strings.HasPrefix(stored, "$2b$") || strings.HasPrefix(stored, "$2y$")
+}
+
+// CheckPassword 校验密码。stored 为明文（旧数据）时使用常量时间比较；
+// needsRehash 表示密码正确但应重新计算哈希后保存。
+// 用户不存在时以空字符串调用，耗时与正常校验一致
+func CheckPassword(stored, password string) (ok bool, needsRehash bool) {
+	if !IsHashed(stored) {
+		// 仍执行一次哈希比较，避免通过响应时间区分明文与哈希账户
+		bcrypt.CompareHashAndPassword(dummyHash, []byte(password))
+		ok = stored != "" && subtle.ConstantTimeCompare([]byte(stored), []byte(password)) == 1
+		return ok, ok
+	}
+
+	if err := bcrypt.CompareHashAndPassword([]byte(stored), []byte(password)); err != nil {
+		return false, false
+	}
+	cost, err := bcrypt.Cost([]byte(stored))
+	return true, err != nil || cost < PasswordCost
+}
+
+// UpgradePasswords 将用户数据中的明文密码替换为哈希，启动时调用
+func UpgradePasswords(store storage.Store) error {
+	if err := store.Lock(); err != nil {
+		return err
+	}
+	defer store.Unlock()
+
+	users, err := store.GetUsers()
+	if err != nil {
+		return err
+	}
+
+	upgraded := 0
+	for key, user := range users {
+		if IsHashed(user.Password) || user.Password == "" {
+			continue
+		}
+		hash, err := HashPassword(user.Password)
+		if err != nil {
+			return err
+		}
+		user.Password = hash
+		users[key] = user
+		upgraded++
+	}
+
+	if upgraded == 0 {
+		return nil
+	}
+	if err := store.SaveUsers(users); err != nil {
+		return err
+	}
+
+	log.Printf("已将 %d 个用户的明文密码升级为哈希", upgraded)
+	return nil
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"fmt"
+	"strings"
+)
+
+// ParseRoleMap 解析 "组=角色" 形式的逗号分隔映射，如 "navdesk-admins=admin,staff=editor"
+func ParseRoleMap(value string) (map[string]string, error) {
+	roleMap := make(map[string]string)
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		index := strings.LastIndex(item, "=")
+		if index <= 0 {
+			return nil, fmt.Errorf("无效的角色映射: %s", item)
+		}
+		group := strings.TrimSpace(item[:index])
+		role := strings.TrimSpace(item[index+1:])
+		if RoleLevel(role) == 0 {
+			return nil, fmt.Errorf("无效的角色: %s", role)
+		}
+		roleMap[group] = role
+	}
+	return roleMap, nil
+}
+
+// MapRole 返回一组外部组/角色名映射后的最高角色，没有匹配时返回空字符串
+func MapRole(groups []string, roleMap map[string]string) string {
+	role := ""
+	for _, group := range groups {
+		if mapped, ok := roleMap[group]; ok && RoleLevel(mapped) > RoleLevel(role) {
+			role = mapped
+		}
+	}
+	return role
+}
@@ -0,0 +1,40 @@
+package auth
+
+import "navdesk/models"
+
+// 权限等级：令牌的权限范围与用户角色一一对应，
+// bookmarks:read 对应查看者，bookmarks:write 对应编辑者，admin 对应管理员
+var (
+	scopeLevels = map[string]int{
+		models.ScopeBookmarksRead:  1,
+		models.ScopeBookmarksWrite: 2,
+		models.ScopeAdmin:          3,
+	}
+	roleLevels = map[string]int{
+		models.RoleViewer: 1,
+		models.RoleEditor: 2,
+		models.RoleAdmin:  3,
+	}
+)
+
+// ValidScope 判断权限范围是否有效
+func ValidScope(scope string) bool {
+	_, ok := scopeLevels[scope]
+	return ok
+}
+
+// RoleLevel 返回角色的权限等级，未知角色为 0
+func RoleLevel(role string) int {
+	return roleLevels[role]
+}
+
+// ScopesLevel 返回一组权限范围中的最高等级
+func ScopesLevel(scopes []string) int {
+	level := 0
+	for _, scope := range scopes {
+		if scopeLevels[scope] > level {
+			level = scopeLevels[scope]
+		}
+	}
+	return level
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// throttleBaseDelay 第二次失败后的等待时间，之后每次失败翻倍
+	throttleBaseDelay = time.Second
+	// throttleCleanupInterval 清理过期记录的间隔
+	throttleCleanupInterval = 10 * time.Minute
+)
+
+// LoginThrottle 登录失败限流。按来源 IP 与用户名分别计数：
+// 连续失败后需等待的时间按 1s、2s、4s…指数增长，达到 maxAttempts 次后锁定 lockout 时长；
+// 最近一次失败超过 lockout 时长后计数清零，登录成功时清除该 IP 与用户名的计数
+type LoginThrottle struct {
+	maxAttempts int
+	lockout     time.Duration
+
+	mu          sync.Mutex
+	entries     map[string]*throttleEntry
+	lastCleanup time.Time
+}
+
+type throttleEntry struct {
+	failures    int
+	lastFailure time.Time
+	blockedTill time.Time
+}
+
+// NewLoginThrottle 创建登录限流器，maxAttempts 为 0 表示不限制
+func NewLoginThrottle(maxAttempts int, lockout time.Duration) *LoginThrottle {
+	return &LoginThrottle{
+		maxAttempts: maxAttempts,
+		lockout:     lockout,
+		entries:     make(map[string]*throttleEntry),
+		lastCleanup: time.Now(),
+	}
+}
+
+// Check 返回还需等待的时间，0 表示允许尝试
+func (t *LoginThrottle) Check(ip, username string) time.Duration {
+	if t.maxAttempts <= 0 {
+		return 0
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	var wait time.Duration
+	for _, key := range throttleKeys(ip, username) {
+		if entry, ok := t.entries[key]; ok && entry.blockedTill.After(now) {
+			if d := entry.blockedTill.Sub(now); d > wait {
+				wait = d
+			}
+		}
+	}
+	return wait
+}
+
+// Failure 记录一次失败，返回 IP 与用户名中最大的失败次数以及是否因此进入锁定
+func (t *LoginThrottle) Failure(ip, username string) (failures int, locked bool) {
+	if t.maxAttempts <= 0 {
+		return 0, false
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	t.cleanup(now)
+
+	for _, key := range throttleKeys(ip, username) {
+		entry, ok := t.entries[key]
+		if !ok || now.Sub(entry.lastFailure) > t.lockout {
+			entry = &throttleEntry{}
+			t.entries[key] = entry
+		}
+
+		entry.failures++
+		entry.lastFailure = now
+		if entry.failures >= t.maxAttempts {
+			entry.blockedTill = now.Add(t.lockout)
+			if entry.failures == t.maxAttempts {
+				locked = true
+			}
+		} else if entry.failures > 1 {
+			delay := throttleBaseDelay << (entry.failures - 2)
+			if delay > t.lockout {
+				delay = t.lockout
+			}
+			entry.blockedTill = now.Add(delay)
+		}
+
+		if entry.failures > failures {
+			failures = entry.failures
+		}
+	}
+	return failures, locked
+}
+
+// Success 登录成功，清除 IP 与用户名的失败计数
+func (t *LoginThrottle) Success(ip, username string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, key := range throttleKeys(ip, username) {
+		delete(t.entries, key)
+	}
+}
+
+// cleanup 删除已过期的记录，调用方需持有 t.mu
+func (t *LoginThrottle) cleanup(now time.Time) {
+	if now.Sub(t.lastCleanup) < throttleCleanupInterval {
+		return
+	}
+	t.lastCleanup = now
+
+	for key, entry := range t.entries {
+		if now.Sub(entry.lastFailure) > t.lockout && now.After(entry.blockedTill) {
+			delete(t.entries, key)
+		}
+	}
+}
+
+func throttleKeys(ip, username string) []string {
+	return []string{"ip:" + ip, "user:" + username}
+}
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfc6238Secret RFC 6238 附录 B 中 SHA-1 测试向量的密钥 "12345678901234567890"
+const rfc6238Secret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	// 附录 B 的参考值为 8 位，6 位验证码取其末 6 位
+	tests := []struct {
+		unix int64
+		want string
+	}{
+		{unix: 59, want: "94287082"},
+		{unix: 1111111109, want: "07081804"},
+		{unix: 1111111111, want: "14050471"},
+		{unix: 1234567890, want: "89005924"},
+		{unix: 2000000000, want: "69279037"},
+		{unix: 20000000000, want: "65353130"},
+	}
+
+	for _, tt := range tests {
+		code, err := TOTPCode(rfc6238Secret, TOTPStep(time.Unix(tt.unix, 0)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := tt.want[2:]; code != want {
+			t.Errorf("T=%d: TOTPCode = %s, want %s", tt.unix, code, want)
+		}
+	}
+}
+
+func TestValidateTOTPSkew(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+
+	tests := []struct {
+		name   string
+		offset int64
+		valid  bool
+	}{
+		{name: "当前周期", offset: 0, valid: true},
+		{name: "上一个周期", offset: -1, valid: true},
+		{name: "下一个周期", offset: 1, valid: true},
+		{name: "两个周期之前", offset: -2, valid: false},
+		{name: "两个周期之后", offset: 2, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			code, err := TOTPCode(rfc6238Secret, current+tt.offset)
+			if err != nil {
+				t.Fatal(err)
+			}
+			step, ok := ValidateTOTP(rfc6238Secret, code, now, 0)
+			if ok != tt.valid {
+				t.Fatalf("ValidateTOTP = %v, want %v", ok, tt.valid)
+			}
+			if ok && step != current+tt.offset {
+				t.Fatalf("step = %d, want %d", step, current+tt.offset)
+			}
+		})
+	}
+}
+
+func TestValidateTOTPRejectsReplay(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := TOTPStep(now)
+	code, err := TOTPCode(rfc6238Secret, current)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	step, ok := ValidateTOTP(rfc6238Secret, code, now, 0)
+	if !ok {
+		t.Fatal("首次使用的验证码未通过")
+	}
+	// 记录已使用的时间步后，同一验证码在允许的偏差内都不能再次使用
+	for _, at := range []time.Time{now, now.Add(30 * time.Second)} {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, at, step); ok {
+			t.Fatalf("%s 重放的验证码通过了校验", at.Format(time.RFC3339))
+		}
+	}
+	// 更早时间步的验证码同样不能使用
+	previous, err := TOTPCode(rfc6238Secret, current-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, previous, now, step); ok {
+		t.Fatal("早于已使用时间步的验证码通过了校验")
+	}
+	// 下一个周期的新验证码可以使用
+	next, err := TOTPCode(rfc6238Secret, current+1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := ValidateTOTP(rfc6238Secret, next, now.Add(30*time.Second), step); !ok {
+		t.Fatal("下一个周期的验证码未通过")
+	}
+}
+
+func TestValidateTOTPRejectsMalformedCode(t *testing.T) {
+	now := time.Unix(59, 0)
+	for _, code := range []string{"", "28708", "2870820", "94287082", "abcdef"} {
+		if _, ok := ValidateTOTP(rfc6238Secret, code, now, 0); ok {
+			t.Errorf("ValidateTOTP(%q) 通过了校验", code)
+		}
+	}
+	// 首尾空白不影响校验
+	if _, ok := ValidateTOTP(rfc6238Secret, " 287082 ", now, 0); !ok {
+		t.Error("带空白的验证码未通过")
+	}
+}
+
+func TestUseRecoveryCodeOnce(t *testing.T) {
+	codes, hashes, err := GenerateRecoveryCodes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != RecoveryCodeCount || len(hashes) != RecoveryCodeCount {
+		t.Fatalf("生成了 %d 个恢复码、%d 个哈希", len(codes), len(hashes))
+	}
+
+	original := strings.Join(hashes, ",")
+
+	// 忽略大小写、空格与连字符
+	remaining, ok := UseRecoveryCode(hashes, " "+strings.ToUpper(strings.ReplaceAll(codes[3], "-", ""))+" ")
+	if !ok {
+		t.Fatal("恢复码未通过")
+	}
+	if len(remaining) != RecoveryCodeCount-1 {
+		t.Fatalf("使用后剩余 %d 个恢复码", len(remaining))
+	}
+	if strings.Join(hashes, ",") != original {
+		t.Fatal("UseRecoveryCode 修改了传入的哈希列表")
+	}
+
+	// 同一恢复码不能再次使用，其他恢复码仍然可用
+	if _, ok := UseRecoveryCode(remaining, codes[3]); ok {
+		t.Fatal("已使用的恢复码再次通过")
+	}
+	if _, ok := UseRecoveryCode(remaining, codes[4]); !ok {
+		t.Fatal("未使用的恢复码未通过")
+	}
+	if _, ok := UseRecoveryCode(remaining, ""); ok {
+		t.Fatal("空的恢复码通过了校验")
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"navdesk/storage"
+)
+
+// runCheck 执行 navdesk check 子命令，返回进程退出码：
+// 0 表示没有（剩余）问题，1 表示存在未修复的问题，2 表示检查本身失败
+func runCheck(args []string) int {
+	flags := flag.NewFlagSet("check", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "修复孤立书签、重复ID与未引用的上传文件（修复前自动创建快照）")
+	flags.Usage = func() {
+		fmt.Fprintln(flags.Output(), "用法: navdesk check [--repair]")
+		flags.PrintDefaults()
+	}
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	store, err := storage.New(os.Getenv("STORAGE_DRIVER"), os.Getenv("DATA_DIR"))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "初始化存储失败: %v\n", err)
+		return 2
+	}
+	defer store.Close()
+
+	if *repair {
+		// 与运行中的服务共用写锁，避免修复过程中数据被修改
+		if err := store.Lock(); err != nil {
+			fmt.Fprintf(os.Stderr, "获取写锁失败: %v\n", err)
+			return 2
+		}
+		defer store.Unlock()
+
+		snapshot, err := storage.NewSnapshotManager(store, envInt("SNAPSHOT_KEEP", 20)).Create(storage.SnapshotPreRepair)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "创建快照失败，已取消修复: %v\n", err)
+			return 2
+		}
+		fmt.Printf("修复前已创建快照: %s\n", snapshot.ID)
+	}
+
+	report, err := storage.CheckIntegrity(store, *repair)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "数据完整性检查失败: %v\n", err)
+		return 2
+	}
+
+	remaining := 0
+	for _, issue := range report.Issues {
+		status := "问题"
+		if issue.Repaired {
+			status = "已修复"
+		} else {
+			remaining++
+		}
+		fmt.Printf("[%s] %s: %s\n", status, issue.Type, issue.Message)
+	}
+
+	if len(report.Issues) == 0 {
+		fmt.Println("数据完整性检查通过，未发现问题")
+		return 0
+	}
+	fmt.Printf("共发现 %d 个问题，已修复 %d 个，剩余 %d 个\n", len(report.Issues), len(report.Issues)-remaining, remaining)
+	if remaining > 0 {
+		return 1
+	}
+	return 0
+}
@@ -1,10 +1,16 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"strconv"
 	"time"
 
+	"navdesk/auth"
+	"navdesk/middleware"
 	"navdesk/models"
 	"navdesk/storage"
 
@@ -12,15 +18,40 @@ import (
 	"github.com/gin-gonic/gin"
 )
 
+// 两步验证登录过程中保存在会话里的键，以及完成第二步的时限
+const (
+	pendingTwoFactorKey   = "pendingTwoFactor"
+	pendingTwoFactorAtKey = "pendingTwoFactorAt"
+	twoFactorTimeout      = 5 * time.Minute
+)
+
+// LDAPOptions LDAP 登录的认证器与角色映射配置
+type LDAPOptions struct {
+	Authenticator *auth.LDAPAuthenticator
+	// RoleMap 组名到角色的映射，匹配多个时取最高角色
+	RoleMap map[string]string
+	// DefaultRole 没有匹配的组时使用的角色，为空表示拒绝登录
+	DefaultRole string
+}
+
 // AuthHandler 认证处理器
 type AuthHandler struct {
-	storage *storage.Storage
+	storage     storage.Store
+	sessions    *storage.Sessions
+	throttle    *auth.LoginThrottle
+	ldap        *LDAPOptions
+	oidcEnabled bool
 }
 
-// NewAuthHandler 创建认证处理器
-func NewAuthHandler(storage *storage.Storage) *AuthHandler {
+// NewAuthHandler 创建认证处理器。ldap 为空表示只使用本地账号，
+// oidcEnabled 表示是否在登录页显示单点登录入口
+func NewAuthHandler(storage storage.Store, sessions *storage.Sessions, throttle *auth.LoginThrottle, ldap *LDAPOptions, oidcEnabled bool) *AuthHandler {
 	return &AuthHandler{
-		storage: storage,
+		storage:     storage,
+		sessions:    sessions,
+		throttle:    throttle,
+		ldap:        ldap,
+		oidcEnabled: oidcEnabled,
 	}
 }
 
@@ -35,6 +66,19 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	// 登录限流，日志格式固定以便 fail2ban 匹配
+	ip := c.ClientIP()
+	if wait := h.throttle.Check(ip, req.Username); wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		log.Printf("navdesk auth throttled: ip=%s user=%q retry_after=%d", ip, req.Username, seconds)
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.JSON(http.StatusTooManyRequests, models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("登录尝试过于频繁，请 %d 秒后再试", seconds),
+		})
+		return
+	}
+
 	users, err := h.storage.GetUsers()
 	if err != nil {
 		log.Printf("Error reading users: %v", err)
@@ -45,17 +89,49 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 查找用户
+	// 查找用户并校验密码，用户不存在时同样执行一次哈希比较
 	var foundUser *models.User
-	for _, user := range users {
-		if user.Username == req.Username && user.Password == req.Password {
+	var foundKey string
+	for key, user := range users {
+		if user.Username == req.Username {
+			user := user
 			foundUser = &user
+			foundKey = key
 			break
 		}
 	}
 
+	if h.ldap != nil && (foundUser == nil || foundUser.Provider == models.ProviderLDAP) {
+		// 本地账号（如应急管理员）始终使用 users.json 中的密码，其余账号交给 LDAP 校验
+		user, status, message := h.ldapLogin(req.Username, req.Password)
+		if status != 0 {
+			c.JSON(status, models.APIResponse{
+				Success: false,
+				Message: message,
+			})
+			return
+		}
+		foundUser = user
+	} else {
+		stored := ""
+		if foundUser != nil {
+			stored = foundUser.Password
+		}
+		ok, needsRehash := auth.CheckPassword(stored, req.Password)
+		if !ok {
+			foundUser = nil
+		} else if needsRehash {
+			h.rehashPassword(foundKey, req.Password)
+		}
+	}
+
 	if foundUser == nil {
+		failures, locked := h.throttle.Failure(ip, req.Username)
 		log.Printf("登录失败: 用户名 %s - 账号或密码错误", req.Username)
+		log.Printf("navdesk auth failure: ip=%s user=%q failures=%d", ip, req.Username, failures)
+		if locked {
+			log.Printf("navdesk auth lockout: ip=%s user=%q failures=%d", ip, req.Username, failures)
+		}
 		c.JSON(http.StatusUnauthorized, models.APIResponse{
 			Success: false,
 			Message: "账号或密码错误",
@@ -63,29 +139,218 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
-	// 创建会话
+	// 密码校验通过后再提示禁用，避免泄露账号状态
+	if foundUser.Disabled {
+		log.Printf("登录失败: 用户名 %s - 账号已被禁用", req.Username)
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Message: "账号已被禁用",
+		})
+		return
+	}
+
+	// 启用了两步验证时只记录待验证的用户名，验证码通过后才真正登录
+	if foundUser.TOTPEnabled {
+		middleware.EndSession(c, h.sessions)
+		session := sessions.Default(c)
+		session.Set(pendingTwoFactorKey, foundUser.Username)
+		session.Set(pendingTwoFactorAtKey, time.Now().Format(time.RFC3339))
+		if err := session.Save(); err != nil {
+			log.Printf("Session保存失败: %v", err)
+		}
+
+		log.Printf("用户 %s 密码验证通过，等待两步验证", foundUser.Username)
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Message: "请输入两步验证码",
+			Data: map[string]interface{}{
+				"twoFactorRequired": true,
+				"username":          foundUser.Username,
+			},
+		})
+		return
+	}
+
+	h.throttle.Success(ip, req.Username)
+	h.startSession(c, foundUser)
+}
+
+// ldapLogin 通过 LDAP 绑定校验密码，并按所属组映射角色、创建或同步本地账号。
+// 账号或密码错误时返回空用户，由调用方按登录失败处理；其他错误返回响应状态码与提示
+func (h *AuthHandler) ldapLogin(username, password string) (*models.User, int, string) {
+	identity, err := h.ldap.Authenticator.Authenticate(username, password)
+	if errors.Is(err, auth.ErrLDAPInvalidCredentials) {
+		return nil, 0, ""
+	}
+	if err != nil {
+		log.Printf("LDAP 认证失败: 用户名 %s - %v", username, err)
+		return nil, http.StatusServiceUnavailable, "目录服务暂时不可用，请稍后重试"
+	}
+
+	role := auth.MapRole(identity.Groups, h.ldap.RoleMap)
+	if role == "" {
+		role = h.ldap.DefaultRole
+	}
+	user, err := auth.ProvisionUser(h.storage, models.ProviderLDAP, identity.Username, role, true, false)
+	switch {
+	case errors.Is(err, auth.ErrAccessDenied), errors.Is(err, auth.ErrAccountDisabled):
+		return nil, http.StatusForbidden, err.Error()
+	case err != nil:
+		log.Printf("LDAP 登录失败: %s - %v", identity.Username, err)
+		return nil, http.StatusInternalServerError, "登录失败"
+	}
+	return user, 0, ""
+}
+
+// VerifyTwoFactor 登录第二步：校验验证器中的验证码或恢复码，通过后创建会话
+func (h *AuthHandler) VerifyTwoFactor(c *gin.Context) {
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "验证码不能为空",
+		})
+		return
+	}
+
 	session := sessions.Default(c)
-	session.Set("username", foundUser.Username)
-	session.Set("role", foundUser.Role)
-	session.Set("loginTime", time.Now().Format(time.RFC3339))
-	if err := session.Save(); err != nil {
-		log.Printf("Session保存失败: %v", err)
+	username, _ := session.Get(pendingTwoFactorKey).(string)
+	startedAt, _ := session.Get(pendingTwoFactorAtKey).(string)
+	started, err := time.Parse(time.RFC3339, startedAt)
+	if username == "" || err != nil || time.Since(started) > twoFactorTimeout {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Message: "登录已过期，请重新输入账号密码",
+		})
+		return
+	}
+
+	ip := c.ClientIP()
+	if wait := h.throttle.Check(ip, username); wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		log.Printf("navdesk auth throttled: ip=%s user=%q retry_after=%d", ip, username, seconds)
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.JSON(http.StatusTooManyRequests, models.APIResponse{
+			Success: false,
+			Message: fmt.Sprintf("登录尝试过于频繁，请 %d 秒后再试", seconds),
+		})
+		return
+	}
+
+	users, err := h.storage.GetUsers()
+	if err != nil {
+		log.Printf("Error reading users: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "登录失败",
+		})
+		return
+	}
+
+	user, exists := users[username]
+	if !exists || user.Disabled || !user.TOTPEnabled {
+		session.Delete(pendingTwoFactorKey)
+		session.Delete(pendingTwoFactorAtKey)
+		session.Save()
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Message: "登录已过期，请重新输入账号密码",
+		})
+		return
+	}
+
+	if step, ok := auth.ValidateTOTP(user.TOTPSecret, req.Code, time.Now(), user.TOTPLastStep); ok {
+		user.TOTPLastStep = step
+	} else if remaining, ok := auth.UseRecoveryCode(user.RecoveryCodes, req.Code); ok {
+		user.RecoveryCodes = remaining
+		log.Printf("用户 %s 使用恢复码登录，剩余 %d 个", username, len(remaining))
 	} else {
-		log.Printf("Session保存成功: username=%s, role=%s", foundUser.Username, foundUser.Role)
+		failures, locked := h.throttle.Failure(ip, username)
+		log.Printf("登录失败: 用户名 %s - 两步验证码错误", username)
+		log.Printf("navdesk auth failure: ip=%s user=%q failures=%d", ip, username, failures)
+		if locked {
+			log.Printf("navdesk auth lockout: ip=%s user=%q failures=%d", ip, username, failures)
+		}
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Message: "验证码错误",
+		})
+		return
+	}
+
+	// 记录已使用的时间步与恢复码，防止重放
+	users[username] = user
+	if err := h.storage.SaveUsers(users); err != nil {
+		log.Printf("保存两步验证状态失败: %s - %v", username, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "登录失败",
+		})
+		return
+	}
+
+	h.throttle.Success(ip, username)
+	session.Delete(pendingTwoFactorKey)
+	session.Delete(pendingTwoFactorAtKey)
+	h.startSession(c, &user)
+}
+
+// startSession 创建登录会话并返回登录成功响应
+func (h *AuthHandler) startSession(c *gin.Context, user *models.User) {
+	if err := middleware.StartSession(c, h.sessions, user); err != nil {
+		log.Printf("创建会话失败: %s - %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "登录失败",
+		})
+		return
 	}
 
-	log.Printf("用户登录成功: %s (%s)", foundUser.Username, foundUser.Role)
+	log.Printf("用户登录成功: %s (%s)", user.Username, user.Role)
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "登录成功",
 		Data: map[string]interface{}{
-			"username": foundUser.Username,
-			"role":     foundUser.Role,
+			"username": user.Username,
+			"role":     user.Role,
 		},
 	})
 }
 
+// rehashPassword 将明文或强度不足的密码重新计算哈希后保存，失败时只记录日志
+func (h *AuthHandler) rehashPassword(key, password string) {
+	hash, err := auth.HashPassword(password)
+	if err != nil {
+		log.Printf("密码哈希计算失败: %s - %v", key, err)
+		return
+	}
+
+	if err := h.storage.Lock(); err != nil {
+		log.Printf("密码升级获取写锁失败: %s - %v", key, err)
+		return
+	}
+	defer h.storage.Unlock()
+
+	users, err := h.storage.GetUsers()
+	if err != nil {
+		log.Printf("密码升级读取用户失败: %s - %v", key, err)
+		return
+	}
+	user, exists := users[key]
+	if !exists {
+		return
+	}
+	user.Password = hash
+	users[key] = user
+
+	if err := h.storage.SaveUsers(users); err != nil {
+		log.Printf("密码升级保存失败: %s - %v", key, err)
+		return
+	}
+	log.Printf("用户 %s 的密码已升级为哈希", user.Username)
+}
+
 // Logout 用户登出
 func (h *AuthHandler) Logout(c *gin.Context) {
 	session := sessions.Default(c)
@@ -98,10 +363,7 @@ func (h *AuthHandler) Logout(c *gin.Context) {
 		}
 	}
 
-	session.Delete("username")
-	session.Delete("role")
-	session.Delete("loginTime")
-	session.Save()
+	middleware.EndSession(c, h.sessions)
 
 	log.Printf("用户登出成功: %s", usernameStr)
 
@@ -120,6 +382,20 @@ func (h *AuthHandler) Status(c *gin.Context) {
 		role := session.Get("role")
 		loginTime := session.Get("loginTime")
 
+		// 以用户数据中的最新角色为准，管理员修改角色后无需重新登录
+		if users, err := h.storage.GetUsers(); err == nil {
+			for _, user := range users {
+				if user.Username == username {
+					if role != user.Role {
+						session.Set("role", user.Role)
+						session.Save()
+					}
+					role = user.Role
+					break
+				}
+			}
+		}
+
 		c.JSON(http.StatusOK, map[string]interface{}{
 			"success":    true,
 			"isLoggedIn": true,
@@ -133,7 +409,8 @@ func (h *AuthHandler) Status(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, map[string]interface{}{
-		"success":    true,
-		"isLoggedIn": false,
+		"success":     true,
+		"isLoggedIn":  false,
+		"oidcEnabled": h.oidcEnabled,
 	})
 }
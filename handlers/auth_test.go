@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"navdesk/auth"
+	"navdesk/models"
+	"navdesk/storage"
+)
+
+func TestLDAPLoginFallsBackToLocalAccounts(t *testing.T) {
+	store := newTestStore(t)
+	hash, err := auth.HashPassword("break-glass")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveUsers(map[string]models.User{
+		"admin": {Username: "admin", Password: hash, Role: models.RoleAdmin},
+		"bob":   {Username: "bob", Password: hash, Role: models.RoleEditor, Provider: models.ProviderLDAP},
+	}); err != nil {
+		t.Fatal(err)
+	}
+
+	// 目录服务不可用：访问 LDAP 的登录返回 503
+	authenticator, err := auth.NewLDAPAuthenticator(auth.LDAPConfig{
+		URL:        "ldap://127.0.0.1:1",
+		UserFilter: "(uid=%s)",
+		Timeout:    time.Second,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewAuthHandler(store, storage.NewSessions(store, time.Hour, 0), auth.NewLoginThrottle(0, 0),
+		&LDAPOptions{Authenticator: authenticator, DefaultRole: models.RoleViewer}, false)
+
+	r := newTestRouter()
+	r.POST("/api/auth/login", handler.Login)
+
+	tests := []struct {
+		name     string
+		username string
+		password string
+		want     int
+	}{
+		// 本地账号（应急管理员）只校验本地密码，不依赖目录服务
+		{name: "本地账号", username: "admin", password: "break-glass", want: http.StatusOK},
+		{name: "本地账号密码错误", username: "admin", password: "wrong", want: http.StatusUnauthorized},
+		// LDAP 账号与不存在的账号交给目录服务，不能使用本地密码
+		{name: "LDAP 账号", username: "bob", password: "break-glass", want: http.StatusServiceUnavailable},
+		{name: "不存在的账号", username: "carol", password: "break-glass", want: http.StatusServiceUnavailable},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body, _ := json.Marshal(models.LoginRequest{Username: tt.username, Password: tt.password})
+			req := httptest.NewRequest(http.MethodPost, "/api/auth/login", bytes.NewReader(body))
+			req.Header.Set("Content-Type", "application/json")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d, body %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
@@ -14,20 +14,25 @@ import (
 	"navdesk/models"
 	"navdesk/storage"
 
-	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 // BookmarksHandler 书签处理器
 type BookmarksHandler struct {
-	storage *storage.Storage
+	storage storage.Store
+	trash   *storage.Trash
+	history *storage.History
+	urls    *URLPolicy
 }
 
 // NewBookmarksHandler 创建书签处理器
-func NewBookmarksHandler(storage *storage.Storage) *BookmarksHandler {
+func NewBookmarksHandler(storage storage.Store, trash *storage.Trash, history *storage.History, urls *URLPolicy) *BookmarksHandler {
 	return &BookmarksHandler{
 		storage: storage,
+		trash:   trash,
+		history: history,
+		urls:    urls,
 	}
 }
 
@@ -109,6 +114,7 @@ func (h *BookmarksHandler) GetBookmark(c *gin.Context) {
 
 	for _, bookmark := range bookmarks {
 		if bookmark.ID == id {
+			setETag(c, bookmark.Version)
 			c.JSON(http.StatusOK, models.APIResponse{
 				Success: true,
 				Data:    bookmark,
@@ -134,14 +140,13 @@ func (h *BookmarksHandler) CreateBookmark(c *gin.Context) {
 		return
 	}
 
-	// 验证URL格式
-	if _, err := url.Parse(req.URL); err != nil {
-		c.JSON(http.StatusBadRequest, models.APIResponse{
-			Success: false,
-			Message: "网址格式不正确",
-		})
+	// 校验网址协议与图标地址，并规范化
+	normalizedURL, normalizedIcon, errs := h.urls.normalizeBookmarkLinks(req.URL, req.Icon)
+	if len(errs) > 0 {
+		validationFailed(c, errs)
 		return
 	}
+	req.URL, req.Icon = normalizedURL, normalizedIcon
 
 	// 验证分类是否存在
 	categories, err := h.storage.GetCategories()
@@ -197,12 +202,7 @@ func (h *BookmarksHandler) CreateBookmark(c *gin.Context) {
 	icon := req.Icon
 	if icon == "" {
 		// 用户留空：使用书签网址拼接 "/favicon.ico"
-		parsedURL, err := url.Parse(req.URL)
-		if err == nil && parsedURL.Host != "" {
-			icon = parsedURL.Scheme + "://" + parsedURL.Host + "/favicon.ico"
-		} else {
-			icon = "/favicon.ico" // 如果URL解析失败，使用默认图标
-		}
+		icon = siteFavicon(req.URL)
 	} else if strings.ToLower(strings.TrimSpace(icon)) == "local" {
 		// 用户输入 "local"：使用默认图标
 		icon = "/favicon.ico"
@@ -230,6 +230,7 @@ func (h *BookmarksHandler) CreateBookmark(c *gin.Context) {
 		Category:    req.Category,
 		Tags:        req.Tags,
 		Sort:        sort,
+		Version:     1,
 		CreatedAt:   time.Now(),
 	}
 
@@ -237,9 +238,7 @@ func (h *BookmarksHandler) CreateBookmark(c *gin.Context) {
 		newBookmark.Tags = []string{}
 	}
 
-	bookmarks = append(bookmarks, newBookmark)
-
-	if err := h.storage.SaveBookmarks(bookmarks); err != nil {
+	if err := h.storage.PutBookmark(newBookmark); err != nil {
 		log.Printf("书签创建失败: %s - 保存数据失败", req.Name)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -248,15 +247,11 @@ func (h *BookmarksHandler) CreateBookmark(c *gin.Context) {
 		return
 	}
 
-	session := sessions.Default(c)
-	username := session.Get("username")
-	usernameStr := "unknown"
-	if username != nil {
-		usernameStr = username.(string)
-	}
+	username := currentUsername(c)
 
-	log.Printf("书签创建成功: %s (%s) - 用户: %s", newBookmark.Name, newBookmark.Category, usernameStr)
+	log.Printf("书签创建成功: %s (%s) - 用户: %s", newBookmark.Name, newBookmark.Category, username)
 
+	setETag(c, newBookmark.Version)
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "书签创建成功",
@@ -264,22 +259,24 @@ func (h *BookmarksHandler) CreateBookmark(c *gin.Context) {
 	})
 }
 
-// 移动图标到新分类目录
-func (h *BookmarksHandler) moveIconToNewCategory(oldIconPath, oldCategoryId, newCategoryId string, categories []models.Category) string {
-	// 解析旧图标路径
-	if !strings.HasPrefix(oldIconPath, "/uploads/") {
-		log.Printf("图标路径格式不正确: %s", oldIconPath)
-		return oldIconPath
+// siteFavicon 返回网址对应站点的 /favicon.ico，不是 http、https 网址时使用默认图标
+func siteFavicon(rawURL string) string {
+	parsedURL, err := url.Parse(rawURL)
+	if err == nil && parsedURL.Host != "" && (parsedURL.Scheme == "http" || parsedURL.Scheme == "https") {
+		return parsedURL.Scheme + "://" + parsedURL.Host + "/favicon.ico"
 	}
+	return "/favicon.ico"
+}
 
-	parts := strings.Split(strings.TrimPrefix(oldIconPath, "/uploads/"), "/")
-	if len(parts) < 2 {
+// 移动图标到新分类目录，文件移动记录在事务中，书签保存失败时随事务回滚
+func (h *BookmarksHandler) moveIconToNewCategory(tx *storage.Tx, oldIconPath, oldCategoryId, newCategoryId string, categories []models.Category) string {
+	// 解析旧图标路径
+	oldFilePath, err := storage.ResolveUploadURL(h.storage, oldIconPath)
+	if err != nil {
 		log.Printf("图标路径格式不正确: %s", oldIconPath)
 		return oldIconPath
 	}
-
-	oldDir := parts[0]
-	filename := parts[1]
+	filename := filepath.Base(oldFilePath)
 
 	// 获取新分类的上传目录
 	newUploadDir := "common"
@@ -291,23 +288,25 @@ func (h *BookmarksHandler) moveIconToNewCategory(oldIconPath, oldCategoryId, new
 	}
 
 	// 构建文件路径
-	oldFilePath := filepath.Join(h.storage.GetDataPath(), "uploads", oldDir, filename)
-	newDirPath := filepath.Join(h.storage.GetUploadsPath(), newUploadDir)
-	newFilePath := filepath.Join(newDirPath, filename)
+	newFilePath, err := storage.ResolveUploadPath(h.storage, newUploadDir+"/"+filename)
+	if err != nil {
+		log.Printf("分类 %s 的上传目录无效: %q", newCategoryId, newUploadDir)
+		return oldIconPath
+	}
 
 	// 检查旧文件是否存在
-	if _, err := os.Stat(oldFilePath); os.IsNotExist(err) {
+	info, err := os.Stat(oldFilePath)
+	if os.IsNotExist(err) {
 		log.Printf("旧图标文件不存在: %s", oldFilePath)
 		return fmt.Sprintf("/uploads/%s/%s", newUploadDir, filename)
 	}
-
-	// 创建新目录（如果不存在）
-	if _, err := os.Stat(newDirPath); os.IsNotExist(err) {
-		os.MkdirAll(newDirPath, 0755)
+	if err == nil && !info.Mode().IsRegular() {
+		log.Printf("图标路径不是文件: %s", oldIconPath)
+		return oldIconPath
 	}
 
-	// 移动文件
-	if err := os.Rename(oldFilePath, newFilePath); err != nil {
+	// 移动文件（目标目录不存在时自动创建）
+	if err := tx.Rename(oldFilePath, newFilePath); err != nil {
 		log.Printf("移动图标文件失败: %v", err)
 		return oldIconPath
 	}
@@ -328,14 +327,13 @@ func (h *BookmarksHandler) UpdateBookmark(c *gin.Context) {
 		return
 	}
 
-	// 验证URL格式
-	if _, err := url.Parse(req.URL); err != nil {
-		c.JSON(http.StatusBadRequest, models.APIResponse{
-			Success: false,
-			Message: "网址格式不正确",
-		})
+	// 校验网址协议与图标地址，并规范化
+	normalizedURL, normalizedIcon, errs := h.urls.normalizeBookmarkLinks(req.URL, req.Icon)
+	if len(errs) > 0 {
+		validationFailed(c, errs)
 		return
 	}
+	req.URL, req.Icon = normalizedURL, normalizedIcon
 
 	// 验证分类是否存在
 	categories, err := h.storage.GetCategories()
@@ -390,6 +388,12 @@ func (h *BookmarksHandler) UpdateBookmark(c *gin.Context) {
 		return
 	}
 
+	// 检查客户端编辑的是否为最新版本
+	if !ifMatch(c, bookmarks[bookmarkIndex].Version) {
+		preconditionFailed(c, bookmarks[bookmarkIndex].Version, bookmarks[bookmarkIndex])
+		return
+	}
+
 	// 检查同一分类下名称是否与其他书签重复
 	for i, bookmark := range bookmarks {
 		if i != bookmarkIndex && bookmark.Category == req.Category && bookmark.Name == req.Name {
@@ -407,6 +411,18 @@ func (h *BookmarksHandler) UpdateBookmark(c *gin.Context) {
 
 	newIconPath := req.Icon
 
+	// 图标文件的移动、删除与书签保存在同一个事务中完成
+	tx, err := storage.BeginTx(h.storage, storage.TxBookmarks)
+	if err != nil {
+		log.Printf("书签更新失败: %s - 开始事务失败: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "保存书签失败",
+		})
+		return
+	}
+	defer tx.Rollback()
+
 	// 处理图标变化的情况
 	if oldIcon != req.Icon {
 		// 图标发生了变化
@@ -415,18 +431,22 @@ func (h *BookmarksHandler) UpdateBookmark(c *gin.Context) {
 				// 新图标也是本地文件，检查是否需要移动
 				if oldCategory != req.Category {
 					// 分类也变了，移动文件
-					newIconPath = h.moveIconToNewCategory(oldIcon, oldCategory, req.Category, categories)
+					newIconPath = h.moveIconToNewCategory(tx, oldIcon, oldCategory, req.Category, categories)
 				} else {
 					// 分类没变，但图标变了
 					log.Printf("图标已更新，旧图标已在上传时删除: %s -> %s", oldIcon, req.Icon)
 				}
 			} else {
 				// 新图标不是本地文件（可能是网络图标），删除旧的本地图标
-				oldIconPath := filepath.Join(h.storage.GetDataPath(), strings.TrimPrefix(oldIcon, "/"))
-				log.Printf("准备删除旧图标文件: %s -> %s", oldIcon, oldIconPath)
-				if _, err := os.Stat(oldIconPath); err == nil {
-					os.Remove(oldIconPath)
-					log.Printf("旧图标文件删除成功: %s", oldIconPath)
+				oldIconPath, err := storage.ResolveUploadURL(h.storage, oldIcon)
+				if err != nil {
+					log.Printf("拒绝删除上传目录以外的文件: %q", oldIcon)
+				} else if info, err := os.Stat(oldIconPath); err == nil && info.Mode().IsRegular() {
+					if err := tx.Remove(oldIconPath); err != nil {
+						log.Printf("旧图标文件删除失败: %s - %v", oldIconPath, err)
+					} else {
+						log.Printf("旧图标文件删除成功: %s", oldIconPath)
+					}
 				} else {
 					log.Printf("旧图标文件不存在: %s", oldIconPath)
 				}
@@ -435,7 +455,7 @@ func (h *BookmarksHandler) UpdateBookmark(c *gin.Context) {
 	} else {
 		// 图标没变，只检查分类变化
 		if oldCategory != req.Category && oldIcon != "" && strings.HasPrefix(oldIcon, "/uploads/") {
-			newIconPath = h.moveIconToNewCategory(oldIcon, oldCategory, req.Category, categories)
+			newIconPath = h.moveIconToNewCategory(tx, oldIcon, oldCategory, req.Category, categories)
 		}
 	}
 
@@ -443,12 +463,7 @@ func (h *BookmarksHandler) UpdateBookmark(c *gin.Context) {
 	icon := newIconPath
 	if icon == "" {
 		// 用户留空：使用书签网址拼接 "/favicon.ico"
-		parsedURL, err := url.Parse(req.URL)
-		if err == nil && parsedURL.Host != "" {
-			icon = parsedURL.Scheme + "://" + parsedURL.Host + "/favicon.ico"
-		} else {
-			icon = "/favicon.ico" // 如果URL解析失败，使用默认图标
-		}
+		icon = siteFavicon(req.URL)
 	} else if strings.ToLower(strings.TrimSpace(icon)) == "local" {
 		// 用户输入 "local"：使用默认图标
 		icon = "/favicon.ico"
@@ -463,13 +478,14 @@ func (h *BookmarksHandler) UpdateBookmark(c *gin.Context) {
 	bookmarks[bookmarkIndex].Category = req.Category
 	bookmarks[bookmarkIndex].Tags = req.Tags
 	bookmarks[bookmarkIndex].Sort = req.Sort
+	bookmarks[bookmarkIndex].Version = oldBookmark.Version + 1
 	bookmarks[bookmarkIndex].UpdatedAt = time.Now()
 
 	if bookmarks[bookmarkIndex].Tags == nil {
 		bookmarks[bookmarkIndex].Tags = []string{}
 	}
 
-	if err := h.storage.SaveBookmarks(bookmarks); err != nil {
+	if err := h.storage.PutBookmark(bookmarks[bookmarkIndex]); err != nil {
 		log.Printf("书签更新失败: %s - 保存数据失败", req.Name)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -478,15 +494,25 @@ func (h *BookmarksHandler) UpdateBookmark(c *gin.Context) {
 		return
 	}
 
-	session := sessions.Default(c)
-	username := session.Get("username")
-	usernameStr := "unknown"
-	if username != nil {
-		usernameStr = username.(string)
+	if err := tx.Commit(); err != nil {
+		log.Printf("书签更新失败: %s - 提交事务失败: %v", req.Name, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "保存书签失败",
+		})
+		return
 	}
 
-	log.Printf("书签更新成功: %s (%s → %s) - 用户: %s", bookmarks[bookmarkIndex].Name, oldCategory, req.Category, usernameStr)
+	username := currentUsername(c)
 
+	// 记录修订历史
+	if err := h.history.Record(storage.HistoryBookmark, id, oldBookmark, username); err != nil {
+		log.Printf("书签修订记录保存失败: %s - %v", id, err)
+	}
+
+	log.Printf("书签更新成功: %s (%s → %s) - 用户: %s", bookmarks[bookmarkIndex].Name, oldCategory, req.Category, username)
+
+	setETag(c, bookmarks[bookmarkIndex].Version)
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "书签更新成功",
@@ -526,29 +552,17 @@ func (h *BookmarksHandler) DeleteBookmark(c *gin.Context) {
 
 	bookmarkToDelete := bookmarks[bookmarkIndex]
 
-	// 如果书签有本地图标文件，先删除图标文件
-	if bookmarkToDelete.Icon != "" && strings.HasPrefix(bookmarkToDelete.Icon, "/uploads/") {
-		iconPath := filepath.Join(h.storage.GetDataPath(), strings.TrimPrefix(bookmarkToDelete.Icon, "/"))
-		log.Printf("准备删除图标文件: %s -> %s", bookmarkToDelete.Icon, iconPath)
-
-		if _, err := os.Stat(iconPath); err == nil {
-			os.Remove(iconPath)
-			log.Printf("图标文件删除成功: %s", iconPath)
-		} else {
-			log.Printf("图标文件不存在: %s", iconPath)
-		}
+	if !ifMatch(c, bookmarkToDelete.Version) {
+		preconditionFailed(c, bookmarkToDelete.Version, bookmarkToDelete)
+		return
 	}
 
-	// 删除书签记录
-	newBookmarkslist := make([]models.Bookmark, 0, len(bookmarks)-1)
-	for i, bookmark := range bookmarks {
-		if i != bookmarkIndex {
-			newBookmarkslist = append(newBookmarkslist, bookmark)
-		}
-	}
+	username := currentUsername(c)
 
-	if err := h.storage.SaveBookmarks(newBookmarkslist); err != nil {
-		log.Printf("书签删除失败: %s - 保存数据失败", bookmarkToDelete.Name)
+	// 书签及其本地图标文件移入回收站
+	item, err := h.trash.DeleteBookmark(bookmarkToDelete, username)
+	if err != nil {
+		log.Printf("书签删除失败: %s - %v", bookmarkToDelete.Name, err)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Message: "删除书签失败",
@@ -556,18 +570,12 @@ func (h *BookmarksHandler) DeleteBookmark(c *gin.Context) {
 		return
 	}
 
-	session := sessions.Default(c)
-	username := session.Get("username")
-	usernameStr := "unknown"
-	if username != nil {
-		usernameStr = username.(string)
-	}
-
-	log.Printf("书签删除成功: %s (%s) - 用户: %s", bookmarkToDelete.Name, bookmarkToDelete.Category, usernameStr)
+	log.Printf("书签已移入回收站: %s (%s) - 用户: %s", bookmarkToDelete.Name, bookmarkToDelete.Category, username)
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
-		Message: "书签删除成功",
+		Message: "书签已移入回收站",
+		Data:    item,
 	})
 }
 
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"navdesk/middleware"
+	"navdesk/models"
+	"navdesk/storage"
+)
+
+func TestDeleteBookmarkRecordsTokenUser(t *testing.T) {
+	store := newTestStore(t)
+	if err := store.SaveBookmarks([]models.Bookmark{{ID: "bm_1", Name: "示例", URL: "https://example.com", Category: "cat_common", Version: 1}}); err != nil {
+		t.Fatal(err)
+	}
+	tokens := storage.NewTokens(store)
+	_, raw, err := tokens.Create("admin", "脚本", []string{models.ScopeBookmarksWrite}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	trash := storage.NewTrash(store, 0)
+
+	r := newTestRouter()
+	handler := NewBookmarksHandler(store, trash, storage.NewHistory(store, 0), nil)
+	r.DELETE("/api/bookmarks/:id", middleware.RequireAuth(store, tokens), handler.DeleteBookmark)
+
+	req := httptest.NewRequest(http.MethodDelete, "/api/bookmarks/bm_1", nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+
+	items, err := trash.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(items) != 1 || items[0].DeletedBy != "admin" {
+		t.Fatalf("回收站条目 %+v，want 删除人为令牌所属用户 admin", items)
+	}
+}
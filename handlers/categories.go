@@ -5,7 +5,6 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"path/filepath"
 	"sort"
 	"strings"
 	"time"
@@ -13,20 +12,25 @@ import (
 	"navdesk/models"
 	"navdesk/storage"
 
-	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 )
 
 // CategoriesHandler 分类处理器
 type CategoriesHandler struct {
-	storage *storage.Storage
+	storage   storage.Store
+	snapshots *storage.SnapshotManager
+	trash     *storage.Trash
+	history   *storage.History
 }
 
 // NewCategoriesHandler 创建分类处理器
-func NewCategoriesHandler(storage *storage.Storage) *CategoriesHandler {
+func NewCategoriesHandler(storage storage.Store, snapshots *storage.SnapshotManager, trash *storage.Trash, history *storage.History) *CategoriesHandler {
 	return &CategoriesHandler{
-		storage: storage,
+		storage:   storage,
+		snapshots: snapshots,
+		trash:     trash,
+		history:   history,
 	}
 }
 
@@ -67,6 +71,7 @@ func (h *CategoriesHandler) GetCategory(c *gin.Context) {
 
 	for _, category := range categories {
 		if category.ID == id {
+			setETag(c, category.Version)
 			c.JSON(http.StatusOK, models.APIResponse{
 				Success: true,
 				Data:    category,
@@ -92,6 +97,14 @@ func (h *CategoriesHandler) CreateCategory(c *gin.Context) {
 		return
 	}
 
+	if !storage.ValidUploadDir(req.UploadDir) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "上传目录只能包含字母、数字、下划线和横线，且必须以字母或数字开头",
+		})
+		return
+	}
+
 	categories, err := h.storage.GetCategories()
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
@@ -132,6 +145,7 @@ func (h *CategoriesHandler) CreateCategory(c *gin.Context) {
 		Icon:      req.Icon,
 		UploadDir: req.UploadDir,
 		Sort:      req.Sort,
+		Version:   1,
 		CreatedAt: time.Now(),
 	}
 
@@ -139,9 +153,7 @@ func (h *CategoriesHandler) CreateCategory(c *gin.Context) {
 		newCategory.Sort = len(categories)
 	}
 
-	categories = append(categories, newCategory)
-
-	if err := h.storage.SaveCategories(categories); err != nil {
+	if err := h.storage.PutCategory(newCategory); err != nil {
 		log.Printf("分类创建失败: %s - 保存数据失败", req.Name)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -151,20 +163,13 @@ func (h *CategoriesHandler) CreateCategory(c *gin.Context) {
 	}
 
 	// 创建上传目录
-	uploadPath := filepath.Join(h.storage.GetUploadsPath(), req.UploadDir)
-	if _, err := os.Stat(uploadPath); os.IsNotExist(err) {
-		os.MkdirAll(uploadPath, 0755)
-	}
+	ensureUploadDir(h.storage, req.UploadDir)
 
-	session := sessions.Default(c)
-	username := session.Get("username")
-	usernameStr := "unknown"
-	if username != nil {
-		usernameStr = username.(string)
-	}
+	username := currentUsername(c)
 
-	log.Printf("分类创建成功: %s (图标目录: %s) - 用户: %s", newCategory.Name, req.UploadDir, usernameStr)
+	log.Printf("分类创建成功: %s (图标目录: %s) - 用户: %s", newCategory.Name, req.UploadDir, username)
 
+	setETag(c, newCategory.Version)
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "分类创建成功",
@@ -210,6 +215,22 @@ func (h *CategoriesHandler) UpdateCategory(c *gin.Context) {
 		return
 	}
 
+	// 检查客户端编辑的是否为最新版本
+	if !ifMatch(c, categories[categoryIndex].Version) {
+		preconditionFailed(c, categories[categoryIndex].Version, categories[categoryIndex])
+		return
+	}
+
+	// 早期创建的分类可能使用了现在不允许的目录名，未修改且不越界时保留
+	keepUploadDir := req.UploadDir == categories[categoryIndex].UploadDir && safeUploadDir(h.storage, req.UploadDir)
+	if !keepUploadDir && !storage.ValidUploadDir(req.UploadDir) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "上传目录只能包含字母、数字、下划线和横线，且必须以字母或数字开头",
+		})
+		return
+	}
+
 	// 检查名称是否与其他分类重复
 	for i, category := range categories {
 		if i != categoryIndex && category.Name == req.Name {
@@ -232,14 +253,17 @@ func (h *CategoriesHandler) UpdateCategory(c *gin.Context) {
 		}
 	}
 
+	oldCategory := categories[categoryIndex]
+
 	// 更新分类信息
 	categories[categoryIndex].Name = req.Name
 	categories[categoryIndex].Icon = req.Icon
 	categories[categoryIndex].UploadDir = req.UploadDir
 	categories[categoryIndex].Sort = req.Sort
+	categories[categoryIndex].Version = oldCategory.Version + 1
 	categories[categoryIndex].UpdatedAt = time.Now()
 
-	if err := h.storage.SaveCategories(categories); err != nil {
+	if err := h.storage.PutCategory(categories[categoryIndex]); err != nil {
 		log.Printf("分类更新失败: %s - 保存数据失败", req.Name)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -249,20 +273,18 @@ func (h *CategoriesHandler) UpdateCategory(c *gin.Context) {
 	}
 
 	// 创建新的上传目录（如果不存在）
-	uploadPath := filepath.Join(h.storage.GetUploadsPath(), req.UploadDir)
-	if _, err := os.Stat(uploadPath); os.IsNotExist(err) {
-		os.MkdirAll(uploadPath, 0755)
-	}
+	ensureUploadDir(h.storage, req.UploadDir)
+
+	username := currentUsername(c)
 
-	session := sessions.Default(c)
-	username := session.Get("username")
-	usernameStr := "unknown"
-	if username != nil {
-		usernameStr = username.(string)
+	// 记录修订历史
+	if err := h.history.Record(storage.HistoryCategory, id, oldCategory, username); err != nil {
+		log.Printf("分类修订记录保存失败: %s - %v", id, err)
 	}
 
-	log.Printf("分类更新成功: %s (图标目录: %s) - 用户: %s", categories[categoryIndex].Name, req.UploadDir, usernameStr)
+	log.Printf("分类更新成功: %s (图标目录: %s) - 用户: %s", categories[categoryIndex].Name, req.UploadDir, username)
 
+	setETag(c, categories[categoryIndex].Version)
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "分类更新成功",
@@ -311,58 +333,27 @@ func (h *CategoriesHandler) DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	// 处理该分类下的书签
-	bookmarks, err := h.storage.GetBookmarks()
-	if err == nil {
-		// 找到该分类下的所有书签
-		var categoryBookmarks []models.Bookmark
-		var remainingBookmarks []models.Bookmark
-
-		for _, bookmark := range bookmarks {
-			if bookmark.Category == id {
-				categoryBookmarks = append(categoryBookmarks, bookmark)
-
-				// 删除书签的本地图标文件
-				if bookmark.Icon != "" && strings.HasPrefix(bookmark.Icon, "/uploads/") {
-					iconPath := filepath.Join(h.storage.GetDataPath(), strings.TrimPrefix(bookmark.Icon, "/"))
-					if _, err := os.Stat(iconPath); err == nil {
-						os.Remove(iconPath)
-						log.Printf("书签图标文件删除成功: %s", iconPath)
-					}
-				}
-			} else {
-				remainingBookmarks = append(remainingBookmarks, bookmark)
-			}
-		}
-
-		if len(categoryBookmarks) > 0 {
-			log.Printf("准备删除分类 %s 及其下的 %d 个书签", id, len(categoryBookmarks))
-
-			// 保存剩余的书签
-			h.storage.SaveBookmarks(remainingBookmarks)
-			log.Printf("已删除分类 %s 下的所有书签", id)
-		}
+	if !ifMatch(c, categoryToDelete.Version) {
+		preconditionFailed(c, categoryToDelete.Version, categoryToDelete)
+		return
 	}
 
-	// 删除分类的上传目录
-	if categoryToDelete.UploadDir != "" {
-		uploadDirPath := filepath.Join(h.storage.GetUploadsPath(), categoryToDelete.UploadDir)
-		if _, err := os.Stat(uploadDirPath); err == nil {
-			os.RemoveAll(uploadDirPath)
-			log.Printf("分类上传目录删除成功: %s", uploadDirPath)
-		}
+	// 删除前创建快照，误删时可从快照恢复
+	if _, err := h.snapshots.Create(storage.SnapshotDeleteCategory); err != nil {
+		log.Printf("分类删除失败: %s - 创建快照失败: %v", categoryToDelete.Name, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "创建数据快照失败，已取消删除",
+		})
+		return
 	}
 
-	// 删除分类记录
-	newCategories := make([]models.Category, 0, len(categories)-1)
-	for i, category := range categories {
-		if i != categoryIndex {
-			newCategories = append(newCategories, category)
-		}
-	}
+	username := currentUsername(c)
 
-	if err := h.storage.SaveCategories(newCategories); err != nil {
-		log.Printf("分类删除失败: %s - 保存数据失败", categoryToDelete.Name)
+	// 分类、其下的书签及上传目录一并移入回收站
+	item, err := h.trash.DeleteCategory(categoryToDelete, username)
+	if err != nil {
+		log.Printf("分类删除失败: %s - %v", categoryToDelete.Name, err)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Message: "删除分类失败",
@@ -370,17 +361,29 @@ func (h *CategoriesHandler) DeleteCategory(c *gin.Context) {
 		return
 	}
 
-	session := sessions.Default(c)
-	username := session.Get("username")
-	usernameStr := "unknown"
-	if username != nil {
-		usernameStr = username.(string)
-	}
-
-	log.Printf("分类删除成功: %s - 用户: %s", categoryToDelete.Name, usernameStr)
+	log.Printf("分类已移入回收站: %s (含 %d 个书签) - 用户: %s", categoryToDelete.Name, len(item.Bookmarks), username)
 
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
-		Message: "分类删除成功",
+		Message: "分类已移入回收站",
+		Data:    item,
 	})
 }
+
+// safeUploadDir 判断目录是否位于 uploads 内
+func safeUploadDir(store storage.Store, dir string) bool {
+	_, err := storage.ResolveUploadPath(store, dir)
+	return err == nil
+}
+
+// ensureUploadDir 在 uploads 下创建分类的上传目录（如果不存在），目录名越界时只记录日志
+func ensureUploadDir(store storage.Store, dir string) {
+	uploadPath, err := storage.ResolveUploadPath(store, dir)
+	if err != nil {
+		log.Printf("拒绝创建上传目录以外的目录: %q", dir)
+		return
+	}
+	if _, err := os.Stat(uploadPath); os.IsNotExist(err) {
+		os.MkdirAll(uploadPath, 0755)
+	}
+}
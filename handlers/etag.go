@@ -0,0 +1,49 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"navdesk/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// etag 由资源版本号生成 ETag
+func etag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
+
+// setETag 在响应头中写入资源的 ETag
+func setETag(c *gin.Context, version int) {
+	c.Header("ETag", etag(version))
+}
+
+// ifMatch 检查 If-Match 请求头是否与资源当前版本一致。
+// 未携带 If-Match 时视为匹配，以兼容不支持并发检测的客户端
+func ifMatch(c *gin.Context, version int) bool {
+	header := strings.TrimSpace(c.GetHeader("If-Match"))
+	if header == "" {
+		return true
+	}
+
+	current := etag(version)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == current {
+			return true
+		}
+	}
+	return false
+}
+
+// preconditionFailed 返回 412 及资源的当前内容，客户端可据此提示用户合并修改
+func preconditionFailed(c *gin.Context, version int, current interface{}) {
+	setETag(c, version)
+	c.JSON(http.StatusPreconditionFailed, models.APIResponse{
+		Success: false,
+		Message: "数据已被其他人修改，请确认后重试",
+		Data:    current,
+	})
+}
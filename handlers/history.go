@@ -0,0 +1,402 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// errResourceNotFound 修订对应的资源当前不存在
+var errResourceNotFound = errors.New("资源不存在")
+
+// revertConflict 回滚内容与现有数据冲突
+type revertConflict struct {
+	message string
+}
+
+func (e *revertConflict) Error() string {
+	return e.message
+}
+
+// HistoryHandler 修订历史处理器
+type HistoryHandler struct {
+	storage storage.Store
+	history *storage.History
+	urls    *URLPolicy
+}
+
+// NewHistoryHandler 创建修订历史处理器
+func NewHistoryHandler(storage storage.Store, history *storage.History, urls *URLPolicy) *HistoryHandler {
+	return &HistoryHandler{
+		storage: storage,
+		history: history,
+		urls:    urls,
+	}
+}
+
+// resourceID 获取路由中的资源ID，设置为单例资源
+func resourceID(c *gin.Context, resourceType string) string {
+	if resourceType == storage.HistorySettings {
+		return storage.SettingsResourceID
+	}
+	return c.Param("id")
+}
+
+// GetHistory 获取资源的修订列表，最新的修订在前
+func (h *HistoryHandler) GetHistory(resourceType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := resourceID(c, resourceType)
+
+		revisions, err := h.history.List(resourceType, id)
+		if err != nil {
+			log.Printf("读取修订历史失败: %s/%s - %v", resourceType, id, err)
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "获取修订历史失败",
+			})
+			return
+		}
+
+		sort.Slice(revisions, func(i, j int) bool {
+			return revisions[i].Revision > revisions[j].Revision
+		})
+
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Data:    revisions,
+		})
+	}
+}
+
+// GetRevision 获取单个修订及其字段级差异
+func (h *HistoryHandler) GetRevision(resourceType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := resourceID(c, resourceType)
+		revision, err := strconv.Atoi(c.Param("rev"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "无效的修订号",
+			})
+			return
+		}
+
+		current, err := h.current(resourceType, id)
+		if err != nil && err != errResourceNotFound {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "获取修订失败",
+			})
+			return
+		}
+
+		detail, err := h.history.Detail(resourceType, id, revision, current)
+		if err != nil {
+			if errors.Is(err, storage.ErrRevisionNotFound) {
+				c.JSON(http.StatusNotFound, models.APIResponse{
+					Success: false,
+					Message: "修订记录不存在",
+				})
+				return
+			}
+			log.Printf("读取修订失败: %s/%s#%d - %v", resourceType, id, revision, err)
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "获取修订失败",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Data:    detail,
+		})
+	}
+}
+
+// RevertRevision 将资源回滚到指定修订的内容，回滚本身也会产生一条新修订
+func (h *HistoryHandler) RevertRevision(resourceType string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := resourceID(c, resourceType)
+		revision, err := strconv.Atoi(c.Param("rev"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "无效的修订号",
+			})
+			return
+		}
+
+		rev, err := h.history.Get(resourceType, id, revision)
+		if err != nil {
+			if errors.Is(err, storage.ErrRevisionNotFound) {
+				c.JSON(http.StatusNotFound, models.APIResponse{
+					Success: false,
+					Message: "修订记录不存在",
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "获取修订失败",
+			})
+			return
+		}
+
+		// 检查客户端看到的是否为最新版本
+		if current, err := h.current(resourceType, id); err == nil {
+			if version := resourceVersion(current); !ifMatch(c, version) {
+				preconditionFailed(c, version, current)
+				return
+			}
+		}
+
+		username := currentUsername(c)
+
+		var result interface{}
+		switch resourceType {
+		case storage.HistoryBookmark:
+			result, err = h.revertBookmark(id, rev, username)
+		case storage.HistoryCategory:
+			result, err = h.revertCategory(id, rev, username)
+		case storage.HistorySettings:
+			result, err = h.revertSettings(rev, username)
+		}
+
+		if err != nil {
+			var conflict *revertConflict
+			switch {
+			case err == errResourceNotFound:
+				c.JSON(http.StatusNotFound, models.APIResponse{
+					Success: false,
+					Message: "资源不存在，请先从回收站恢复",
+				})
+			case errors.As(err, &conflict):
+				c.JSON(http.StatusConflict, models.APIResponse{
+					Success: false,
+					Message: conflict.message,
+				})
+			default:
+				log.Printf("回滚失败: %s/%s#%d - %v", resourceType, id, revision, err)
+				c.JSON(http.StatusInternalServerError, models.APIResponse{
+					Success: false,
+					Message: "回滚失败",
+				})
+			}
+			return
+		}
+
+		log.Printf("已回滚到修订 #%d: %s/%s - 用户: %s", revision, resourceType, id, username)
+
+		setETag(c, resourceVersion(result))
+		c.JSON(http.StatusOK, models.APIResponse{
+			Success: true,
+			Message: "回滚成功",
+			Data:    result,
+		})
+	}
+}
+
+// current 获取资源的当前内容
+func (h *HistoryHandler) current(resourceType, id string) (interface{}, error) {
+	switch resourceType {
+	case storage.HistoryBookmark:
+		bookmarks, err := h.storage.GetBookmarks()
+		if err != nil {
+			return nil, err
+		}
+		for _, bookmark := range bookmarks {
+			if bookmark.ID == id {
+				return bookmark, nil
+			}
+		}
+	case storage.HistoryCategory:
+		categories, err := h.storage.GetCategories()
+		if err != nil {
+			return nil, err
+		}
+		for _, category := range categories {
+			if category.ID == id {
+				return category, nil
+			}
+		}
+	case storage.HistorySettings:
+		return h.storage.GetSettings()
+	}
+	return nil, errResourceNotFound
+}
+
+// resourceVersion 获取资源的版本号
+func resourceVersion(resource interface{}) int {
+	switch r := resource.(type) {
+	case models.Bookmark:
+		return r.Version
+	case models.Category:
+		return r.Version
+	case models.Settings:
+		return r.Version
+	}
+	return 0
+}
+
+func (h *HistoryHandler) revertBookmark(id string, rev models.Revision, username string) (models.Bookmark, error) {
+	var target models.Bookmark
+	if err := json.Unmarshal(rev.Data, &target); err != nil {
+		return models.Bookmark{}, err
+	}
+
+	bookmarks, err := h.storage.GetBookmarks()
+	if err != nil {
+		return models.Bookmark{}, err
+	}
+
+	bookmarkIndex := -1
+	for i, bookmark := range bookmarks {
+		if bookmark.ID == id {
+			bookmarkIndex = i
+			break
+		}
+	}
+	if bookmarkIndex == -1 {
+		return models.Bookmark{}, errResourceNotFound
+	}
+
+	if target.Category != "all" {
+		categories, err := h.storage.GetCategories()
+		if err != nil {
+			return models.Bookmark{}, err
+		}
+		categoryExists := false
+		for _, category := range categories {
+			if category.ID == target.Category {
+				categoryExists = true
+				break
+			}
+		}
+		if !categoryExists {
+			return models.Bookmark{}, &revertConflict{"修订中的分类已不存在"}
+		}
+	}
+
+	// 早期的修订可能包含现在不允许的网址协议或图标地址
+	normalizedURL, normalizedIcon, errs := h.urls.normalizeBookmarkLinks(target.URL, target.Icon)
+	if len(errs) > 0 {
+		return models.Bookmark{}, &revertConflict{"修订中的" + errs[0].Message}
+	}
+	target.URL, target.Icon = normalizedURL, normalizedIcon
+
+	for i, bookmark := range bookmarks {
+		if i != bookmarkIndex && bookmark.Category == target.Category && bookmark.Name == target.Name {
+			return models.Bookmark{}, &revertConflict{"该分类下已存在相同名称的书签"}
+		}
+	}
+
+	previous := bookmarks[bookmarkIndex]
+	target.ID = id
+	target.Version = previous.Version + 1
+	target.CreatedAt = previous.CreatedAt
+	target.UpdatedAt = time.Now()
+	if target.Tags == nil {
+		target.Tags = []string{}
+	}
+
+	if err := h.storage.PutBookmark(target); err != nil {
+		return models.Bookmark{}, err
+	}
+	if err := h.history.Record(storage.HistoryBookmark, id, previous, username); err != nil {
+		log.Printf("书签修订记录保存失败: %s - %v", id, err)
+	}
+
+	return target, nil
+}
+
+func (h *HistoryHandler) revertCategory(id string, rev models.Revision, username string) (models.Category, error) {
+	var target models.Category
+	if err := json.Unmarshal(rev.Data, &target); err != nil {
+		return models.Category{}, err
+	}
+
+	categories, err := h.storage.GetCategories()
+	if err != nil {
+		return models.Category{}, err
+	}
+
+	categoryIndex := -1
+	for i, category := range categories {
+		if category.ID == id {
+			categoryIndex = i
+			break
+		}
+	}
+	if categoryIndex == -1 {
+		return models.Category{}, errResourceNotFound
+	}
+
+	// 早期版本未校验上传目录名，与当前目录不同且不合法的修订不能回滚
+	keepUploadDir := target.UploadDir == categories[categoryIndex].UploadDir && safeUploadDir(h.storage, target.UploadDir)
+	if !keepUploadDir && !storage.ValidUploadDir(target.UploadDir) {
+		return models.Category{}, &revertConflict{"该修订的上传目录无效"}
+	}
+
+	for i, category := range categories {
+		if i == categoryIndex {
+			continue
+		}
+		if category.Name == target.Name {
+			return models.Category{}, &revertConflict{"分类名称已存在"}
+		}
+		if category.UploadDir == target.UploadDir {
+			return models.Category{}, &revertConflict{"上传目录已存在"}
+		}
+	}
+
+	previous := categories[categoryIndex]
+	target.ID = id
+	target.Version = previous.Version + 1
+	target.CreatedAt = previous.CreatedAt
+	target.UpdatedAt = time.Now()
+
+	if err := h.storage.PutCategory(target); err != nil {
+		return models.Category{}, err
+	}
+
+	// 确保回滚后的上传目录存在
+	ensureUploadDir(h.storage, target.UploadDir)
+
+	if err := h.history.Record(storage.HistoryCategory, id, previous, username); err != nil {
+		log.Printf("分类修订记录保存失败: %s - %v", id, err)
+	}
+
+	return target, nil
+}
+
+func (h *HistoryHandler) revertSettings(rev models.Revision, username string) (models.Settings, error) {
+	var target models.Settings
+	if err := json.Unmarshal(rev.Data, &target); err != nil {
+		return models.Settings{}, err
+	}
+
+	previous, err := h.storage.GetSettings()
+	if err != nil {
+		return models.Settings{}, err
+	}
+	target.Version = previous.Version + 1
+
+	if err := h.storage.SaveSettings(target); err != nil {
+		return models.Settings{}, err
+	}
+	if err := h.history.Record(storage.HistorySettings, storage.SettingsResourceID, previous, username); err != nil {
+		log.Printf("设置修订记录保存失败: %v", err)
+	}
+
+	return target, nil
+}
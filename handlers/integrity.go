@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// IntegrityHandler 数据完整性检查处理器
+type IntegrityHandler struct {
+	storage   storage.Store
+	snapshots *storage.SnapshotManager
+}
+
+// NewIntegrityHandler 创建数据完整性检查处理器
+func NewIntegrityHandler(storage storage.Store, snapshots *storage.SnapshotManager) *IntegrityHandler {
+	return &IntegrityHandler{
+		storage:   storage,
+		snapshots: snapshots,
+	}
+}
+
+// CheckIntegrity 检查数据完整性，只报告不修改
+func (h *IntegrityHandler) CheckIntegrity(c *gin.Context) {
+	report, err := storage.CheckIntegrity(h.storage, false)
+	if err != nil {
+		log.Printf("数据完整性检查失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "数据完整性检查失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    report,
+	})
+}
+
+// RepairIntegrity 检查并修复数据完整性问题，修复前创建快照
+func (h *IntegrityHandler) RepairIntegrity(c *gin.Context) {
+	if _, err := h.snapshots.Create(storage.SnapshotPreRepair); err != nil {
+		log.Printf("数据修复失败: 创建快照失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "创建数据快照失败，已取消修复",
+		})
+		return
+	}
+
+	report, err := storage.CheckIntegrity(h.storage, true)
+	if err != nil {
+		log.Printf("数据修复失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "数据修复失败",
+		})
+		return
+	}
+
+	repaired := 0
+	for _, issue := range report.Issues {
+		if issue.Repaired {
+			repaired++
+		}
+	}
+	log.Printf("数据修复完成: 发现 %d 个问题，修复 %d 个 - 用户: %s", len(report.Issues), repaired, currentUsername(c))
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "数据修复完成",
+		Data:    report,
+	})
+}
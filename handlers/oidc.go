@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"navdesk/auth"
+	"navdesk/middleware"
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// 单点登录过程中保存在会话里的键，以及从跳转到回调的时限
+const (
+	oidcStateKey     = "oidcState"
+	oidcNonceKey     = "oidcNonce"
+	oidcVerifierKey  = "oidcVerifier"
+	oidcStartedAtKey = "oidcStartedAt"
+	oidcTimeout      = 10 * time.Minute
+)
+
+// OIDCOptions 单点登录的账号与角色映射配置
+type OIDCOptions struct {
+	// RedirectURL 回调地址，为空时根据请求的协议与域名推断
+	RedirectURL string
+	// UsernameClaim 作为用户名的声明，如 preferred_username
+	UsernameClaim string
+	// RoleClaim 用于映射角色的声明（字符串或数组），如 groups
+	RoleClaim string
+	// RoleMap 声明值到角色的映射，匹配多个时取最高角色
+	RoleMap map[string]string
+	// DefaultRole 没有匹配的映射时使用的角色，为空表示拒绝登录
+	DefaultRole string
+	// AutoCreate 首次登录时自动创建账号
+	AutoCreate bool
+	// LinkExisting 首次登录时允许关联同名的本地等其他来源账号，关联后按 iss 与 sub 识别
+	LinkExisting bool
+}
+
+// OIDCHandler OpenID Connect 单点登录处理器
+type OIDCHandler struct {
+	storage  storage.Store
+	sessions *storage.Sessions
+	provider *auth.OIDCProvider
+	options  OIDCOptions
+}
+
+// NewOIDCHandler 创建单点登录处理器
+func NewOIDCHandler(storage storage.Store, sessions *storage.Sessions, provider *auth.OIDCProvider, options OIDCOptions) *OIDCHandler {
+	return &OIDCHandler{
+		storage:  storage,
+		sessions: sessions,
+		provider: provider,
+		options:  options,
+	}
+}
+
+// Login 生成 state、nonce 与 PKCE 参数保存到会话，然后跳转到身份提供方
+func (h *OIDCHandler) Login(c *gin.Context) {
+	req, err := auth.NewOIDCRequest()
+	if err != nil {
+		h.fail(c, "生成登录参数失败", err)
+		return
+	}
+
+	target, err := h.provider.AuthCodeURL(c.Request.Context(), req, h.redirectURL(c))
+	if err != nil {
+		h.fail(c, "连接身份提供方失败", err)
+		return
+	}
+
+	session := sessions.Default(c)
+	session.Set(oidcStateKey, req.State)
+	session.Set(oidcNonceKey, req.Nonce)
+	session.Set(oidcVerifierKey, req.CodeVerifier)
+	session.Set(oidcStartedAtKey, time.Now().Format(time.RFC3339))
+	if err := session.Save(); err != nil {
+		h.fail(c, "保存会话失败", err)
+		return
+	}
+
+	c.Redirect(http.StatusFound, target)
+}
+
+// Callback 身份提供方回调：校验 state，用授权码换取并校验 ID Token，映射角色后创建会话
+func (h *OIDCHandler) Callback(c *gin.Context) {
+	session := sessions.Default(c)
+	state, _ := session.Get(oidcStateKey).(string)
+	nonce, _ := session.Get(oidcNonceKey).(string)
+	verifier, _ := session.Get(oidcVerifierKey).(string)
+	startedAt, _ := session.Get(oidcStartedAtKey).(string)
+
+	// state 只能使用一次
+	session.Delete(oidcStateKey)
+	session.Delete(oidcNonceKey)
+	session.Delete(oidcVerifierKey)
+	session.Delete(oidcStartedAtKey)
+	session.Save()
+
+	if errCode := c.Query("error"); errCode != "" {
+		log.Printf("单点登录失败: 身份提供方返回错误 %s %s", errCode, c.Query("error_description"))
+		h.redirectError(c, "denied")
+		return
+	}
+
+	started, err := time.Parse(time.RFC3339, startedAt)
+	if state == "" || c.Query("state") != state || err != nil || time.Since(started) > oidcTimeout {
+		log.Printf("单点登录失败: state 无效或已过期")
+		h.redirectError(c, "expired")
+		return
+	}
+
+	claims, err := h.provider.Exchange(c.Request.Context(), c.Query("code"), auth.OIDCRequest{
+		State:        state,
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+	}, h.redirectURL(c))
+	if err != nil {
+		log.Printf("单点登录失败: %v", err)
+		h.redirectError(c, "failed")
+		return
+	}
+
+	user, errCode := h.resolveUser(claims)
+	if user == nil {
+		h.redirectError(c, errCode)
+		return
+	}
+
+	// 关联的本地账号启用了两步验证时仍需输入验证码，由登录页继续完成第二步；
+	// 单点登录账号的多因素认证由身份提供方负责
+	if user.Provider != models.ProviderOIDC && user.TOTPEnabled {
+		middleware.EndSession(c, h.sessions)
+		session.Set(pendingTwoFactorKey, user.Username)
+		session.Set(pendingTwoFactorAtKey, time.Now().Format(time.RFC3339))
+		if err := session.Save(); err != nil {
+			h.fail(c, "保存会话失败", err)
+			return
+		}
+		log.Printf("用户 %s 单点登录通过，等待两步验证", user.Username)
+		c.Redirect(http.StatusFound, "/admin/login.html?sso_2fa=1")
+		return
+	}
+
+	session.Delete(pendingTwoFactorKey)
+	session.Delete(pendingTwoFactorAtKey)
+	if err := middleware.StartSession(c, h.sessions, user); err != nil {
+		h.fail(c, "创建会话失败", err)
+		return
+	}
+
+	log.Printf("用户单点登录成功: %s (%s)", user.Username, user.Role)
+	c.Redirect(http.StatusFound, "/admin/categories.html")
+}
+
+// resolveUser 按 iss 与 sub 查找关联的账号并映射角色，首次登录时按用户名声明创建或关联账号；
+// 失败时返回登录页使用的错误代码
+func (h *OIDCHandler) resolveUser(claims map[string]interface{}) (*models.User, string) {
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	usernames := auth.ClaimStrings(claims, h.options.UsernameClaim)
+	if len(usernames) == 0 || usernames[0] == "" {
+		log.Printf("单点登录失败: ID Token 中没有 %s 声明 (sub=%v)", h.options.UsernameClaim, claims["sub"])
+		return nil, "failed"
+	}
+
+	role := auth.MapRole(auth.ClaimStrings(claims, h.options.RoleClaim), h.options.RoleMap)
+	if role == "" {
+		role = h.options.DefaultRole
+	}
+	user, err := auth.ProvisionOIDCUser(h.storage, issuer, subject, usernames[0], role, h.options.AutoCreate, h.options.LinkExisting)
+	switch {
+	case errors.Is(err, auth.ErrAccessDenied):
+		return nil, "denied"
+	case errors.Is(err, auth.ErrAccountDisabled):
+		return nil, "disabled"
+	case err != nil:
+		log.Printf("单点登录失败: %s - %v", usernames[0], err)
+		return nil, "failed"
+	}
+	return user, ""
+}
+
+// redirectURL 返回回调地址，未配置时根据当前请求推断
+func (h *OIDCHandler) redirectURL(c *gin.Context) string {
+	if h.options.RedirectURL != "" {
+		return h.options.RedirectURL
+	}
+	scheme := "http"
+	if c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https" {
+		scheme = "https"
+	}
+	return scheme + "://" + c.Request.Host + "/api/auth/oidc/callback"
+}
+
+// redirectError 跳转回登录页并带上错误代码，由登录页显示对应提示
+func (h *OIDCHandler) redirectError(c *gin.Context, code string) {
+	c.Redirect(http.StatusFound, "/admin/login.html?sso_error="+url.QueryEscape(code))
+}
+
+func (h *OIDCHandler) fail(c *gin.Context, message string, err error) {
+	log.Printf("单点登录: %s - %v", message, err)
+	h.redirectError(c, "failed")
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"navdesk/auth"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+func TestOIDCCallbackRejectsInvalidState(t *testing.T) {
+	store := newTestStore(t)
+	// 身份提供方不可达：state 校验失败时不应访问身份提供方
+	provider := auth.NewOIDCProvider(auth.OIDCConfig{Issuer: "http://127.0.0.1:1", ClientID: "navdesk"})
+	handler := NewOIDCHandler(store, nil, provider, OIDCOptions{UsernameClaim: "preferred_username"})
+
+	r := newTestRouter()
+	r.GET("/start", func(c *gin.Context) {
+		session := sessions.Default(c)
+		session.Set(oidcStateKey, "state-1")
+		session.Set(oidcStartedAtKey, c.Query("startedAt"))
+		session.Save()
+	})
+	r.GET("/api/auth/oidc/callback", handler.Callback)
+
+	start := func(startedAt time.Time) []*http.Cookie {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/start?startedAt="+startedAt.Format(time.RFC3339), nil))
+		return w.Result().Cookies()
+	}
+
+	tests := []struct {
+		name    string
+		cookies []*http.Cookie
+		state   string
+	}{
+		{name: "会话中没有 state", state: "state-1"},
+		{name: "state 不匹配", cookies: start(time.Now()), state: "state-2"},
+		{name: "缺少 state 参数", cookies: start(time.Now())},
+		{name: "登录已超时", cookies: start(time.Now().Add(-oidcTimeout - time.Minute)), state: "state-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/auth/oidc/callback?code=c1&state="+tt.state, nil)
+			for _, cookie := range tt.cookies {
+				req.AddCookie(cookie)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != http.StatusFound || w.Header().Get("Location") != "/admin/login.html?sso_error=expired" {
+				t.Fatalf("status %d, Location %q", w.Code, w.Header().Get("Location"))
+			}
+		})
+	}
+}
@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+
+	"navdesk/middleware"
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SessionsHandler 登录会话处理器，只处理当前登录用户自己的会话
+type SessionsHandler struct {
+	sessions *storage.Sessions
+}
+
+// NewSessionsHandler 创建登录会话处理器
+func NewSessionsHandler(sessions *storage.Sessions) *SessionsHandler {
+	return &SessionsHandler{
+		sessions: sessions,
+	}
+}
+
+// GetSessions 获取当前用户已登录的会话，标记发起请求的会话
+func (h *SessionsHandler) GetSessions(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	sessions, err := h.sessions.List(middleware.CurrentUser(c).Username)
+	if err != nil {
+		log.Printf("读取会话失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "获取会话失败",
+		})
+		return
+	}
+
+	current := middleware.SessionID(c)
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == current
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    sessions,
+	})
+}
+
+// RevokeSession 注销当前用户的某个会话，注销当前会话等同于登出
+func (h *SessionsHandler) RevokeSession(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	id := c.Param("id")
+	user := middleware.CurrentUser(c)
+
+	session, err := h.sessions.Get(id)
+	if err == nil && session.Username != user.Username {
+		err = storage.ErrSessionNotFound
+	}
+	if err == nil {
+		if id == middleware.SessionID(c) {
+			middleware.EndSession(c, h.sessions)
+		} else {
+			err = h.sessions.Revoke(id)
+		}
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrSessionNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Message: "会话不存在",
+			})
+			return
+		}
+		log.Printf("注销会话失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "注销会话失败",
+		})
+		return
+	}
+
+	log.Printf("会话已注销: %s (%s, %s) - 用户: %s", session.ID, session.IP, session.UserAgent, user.Username)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "会话已注销",
+	})
+}
+
+// RevokeOtherSessions 注销当前用户在其他设备上的全部会话
+func (h *SessionsHandler) RevokeOtherSessions(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	revoked, err := h.sessions.RevokeUser(user.Username, middleware.SessionID(c))
+	if err != nil {
+		log.Printf("注销会话失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "注销会话失败",
+		})
+		return
+	}
+
+	log.Printf("已注销用户 %s 的 %d 个其他会话", user.Username, revoked)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "已注销其他设备上的会话",
+		Data: map[string]interface{}{
+			"revoked": revoked,
+		},
+	})
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"log"
 	"net/http"
 	"strings"
 
@@ -12,13 +13,15 @@ import (
 
 // SettingsHandler 设置处理器
 type SettingsHandler struct {
-	storage *storage.Storage
+	storage storage.Store
+	history *storage.History
 }
 
 // NewSettingsHandler 创建设置处理器
-func NewSettingsHandler(storage *storage.Storage) *SettingsHandler {
+func NewSettingsHandler(storage storage.Store, history *storage.History) *SettingsHandler {
 	return &SettingsHandler{
 		storage: storage,
+		history: history,
 	}
 }
 
@@ -33,6 +36,7 @@ func (h *SettingsHandler) GetSettings(c *gin.Context) {
 		return
 	}
 
+	setETag(c, settings.Version)
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Data:    settings,
@@ -91,6 +95,22 @@ func (h *SettingsHandler) UpdateSettings(c *gin.Context) {
 		Theme:        req.Theme,
 	}
 
+	previous, err := h.storage.GetSettings()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "获取设置失败",
+		})
+		return
+	}
+
+	// 检查客户端编辑的是否为最新版本
+	if !ifMatch(c, previous.Version) {
+		preconditionFailed(c, previous.Version, previous)
+		return
+	}
+	settings.Version = previous.Version + 1
+
 	if err := h.storage.SaveSettings(settings); err != nil {
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
@@ -99,6 +119,12 @@ func (h *SettingsHandler) UpdateSettings(c *gin.Context) {
 		return
 	}
 
+	// 记录修订历史
+	if err := h.history.Record(storage.HistorySettings, storage.SettingsResourceID, previous, currentUsername(c)); err != nil {
+		log.Printf("设置修订记录保存失败: %v", err)
+	}
+
+	setETag(c, settings.Version)
 	c.JSON(http.StatusOK, models.APIResponse{
 		Success: true,
 		Message: "设置保存成功",
@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+
+	"navdesk/middleware"
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// SnapshotsHandler 快照处理器
+type SnapshotsHandler struct {
+	snapshots *storage.SnapshotManager
+}
+
+// NewSnapshotsHandler 创建快照处理器
+func NewSnapshotsHandler(snapshots *storage.SnapshotManager) *SnapshotsHandler {
+	return &SnapshotsHandler{
+		snapshots: snapshots,
+	}
+}
+
+// GetSnapshots 获取快照列表
+func (h *SnapshotsHandler) GetSnapshots(c *gin.Context) {
+	snapshots, err := h.snapshots.List()
+	if err != nil {
+		log.Printf("读取快照列表失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "获取快照列表失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    snapshots,
+	})
+}
+
+// CreateSnapshot 手动创建快照
+func (h *SnapshotsHandler) CreateSnapshot(c *gin.Context) {
+	snapshot, err := h.snapshots.Create(storage.SnapshotManual)
+	if err != nil {
+		log.Printf("快照创建失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "创建快照失败",
+		})
+		return
+	}
+
+	log.Printf("快照创建成功: %s - 用户: %s", snapshot.ID, currentUsername(c))
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "快照创建成功",
+		Data:    snapshot,
+	})
+}
+
+// DownloadSnapshot 下载快照归档
+func (h *SnapshotsHandler) DownloadSnapshot(c *gin.Context) {
+	id := c.Param("id")
+
+	path, err := h.snapshots.Path(id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "快照不存在",
+		})
+		return
+	}
+
+	c.FileAttachment(path, "navdesk-"+id+".tar.gz")
+}
+
+// RestoreSnapshot 从快照恢复数据
+func (h *SnapshotsHandler) RestoreSnapshot(c *gin.Context) {
+	id := c.Param("id")
+
+	if _, err := h.snapshots.Path(id); err != nil {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "快照不存在",
+		})
+		return
+	}
+
+	if err := h.snapshots.Restore(id); err != nil {
+		log.Printf("快照恢复失败: %s - %v", id, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "恢复快照失败，数据未改变",
+		})
+		return
+	}
+
+	log.Printf("快照恢复成功: %s - 用户: %s", id, currentUsername(c))
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "快照恢复成功",
+	})
+}
+
+// currentUsername 获取当前请求的用户名（会话或 API 令牌），用于日志与权限判断
+func currentUsername(c *gin.Context) string {
+	if user := middleware.CurrentUser(c); user != nil {
+		return user.Username
+	}
+	session := sessions.Default(c)
+	if username, ok := session.Get("username").(string); ok {
+		return username
+	}
+	return "unknown"
+}
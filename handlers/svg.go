@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// errInvalidSVG 上传的 SVG 不是格式正确的 SVG 文档
+var errInvalidSVG = errors.New("SVG 文件格式不正确")
+
+// svgForbiddenElements 会执行脚本、嵌入其他文档或通过动画改写属性的元素，连同子元素一起移除（按小写比较）
+var svgForbiddenElements = map[string]bool{
+	"script":           true,
+	"foreignobject":    true,
+	"iframe":           true,
+	"object":           true,
+	"embed":            true,
+	"audio":            true,
+	"video":            true,
+	"handler":          true,
+	"listener":         true,
+	"set":              true,
+	"animate":          true,
+	"animatemotion":    true,
+	"animatetransform": true,
+}
+
+var (
+	// svgCSSURLPattern 匹配 CSS 中的 url(...) 引用
+	svgCSSURLPattern = regexp.MustCompile(`(?i)url\(\s*['"]?\s*([^'")\s]*)`)
+	// svgDataImagePattern <image> 允许内嵌的位图
+	svgDataImagePattern = regexp.MustCompile(`(?i)^data:image/(png|jpeg|gif|webp);base64,`)
+
+	svgTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	svgAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;", "\t", "&#x9;", "\n", "&#xA;", "\r", "&#xD;")
+)
+
+// sanitizeSVG 清理上传的 SVG：移除脚本与可嵌入内容的元素、事件属性（on*）、
+// 指向外部的 href 与 CSS url()、DOCTYPE 和处理指令，返回重新序列化的文档。
+// 根元素不是 svg 或文档格式不正确时返回 errInvalidSVG
+func sanitizeSVG(data []byte) ([]byte, error) {
+	decoder := xml.NewDecoder(bytes.NewReader(data))
+
+	var out bytes.Buffer
+	var stack []string
+	skipDepth := 0
+	rootSeen := false
+	// style 元素内的文本可能被 CDATA 或注释拆成多段，
+	// 先全部收集，在对应的结束标签处统一检查后输出
+	var style *strings.Builder
+
+	for {
+		token, err := decoder.RawToken()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, errInvalidSVG
+		}
+
+		switch t := token.(type) {
+		case xml.StartElement:
+			if len(stack) == 0 {
+				if rootSeen || !strings.EqualFold(t.Name.Local, "svg") {
+					return nil, errInvalidSVG
+				}
+				rootSeen = true
+			}
+			name := svgName(t.Name)
+			stack = append(stack, name)
+
+			// style 内不保留子元素
+			if skipDepth > 0 || style != nil || svgForbiddenElements[strings.ToLower(t.Name.Local)] {
+				skipDepth++
+				continue
+			}
+
+			out.WriteString("<" + name)
+			for _, attr := range t.Attr {
+				if safeSVGAttr(t.Name.Local, attr) {
+					out.WriteString(" " + svgName(attr.Name) + `="` + svgAttrEscaper.Replace(attr.Value) + `"`)
+				}
+			}
+			out.WriteString(">")
+			if strings.EqualFold(t.Name.Local, "style") {
+				style = &strings.Builder{}
+			}
+
+		case xml.EndElement:
+			name := svgName(t.Name)
+			if len(stack) == 0 || stack[len(stack)-1] != name {
+				return nil, errInvalidSVG
+			}
+			stack = stack[:len(stack)-1]
+
+			if skipDepth > 0 {
+				skipDepth--
+				continue
+			}
+			if style != nil {
+				if text := style.String(); safeSVGStyle(text) {
+					out.WriteString(svgTextEscaper.Replace(text))
+				}
+				style = nil
+			}
+			out.WriteString("</" + name + ">")
+
+		case xml.CharData:
+			if len(stack) == 0 {
+				// 根元素之外只允许空白
+				if len(bytes.TrimSpace(t)) > 0 {
+					return nil, errInvalidSVG
+				}
+				continue
+			}
+			if skipDepth > 0 {
+				continue
+			}
+			if style != nil {
+				style.Write(t)
+				continue
+			}
+			out.WriteString(svgTextEscaper.Replace(string(t)))
+
+		case xml.ProcInst:
+			// 只保留 XML 声明，丢弃 xml-stylesheet 等处理指令
+			if t.Target == "xml" && out.Len() == 0 {
+				out.WriteString("<?xml " + string(t.Inst) + "?>\n")
+			}
+
+		case xml.Directive, xml.Comment:
+			// 丢弃 DOCTYPE（可声明实体）与注释
+		}
+	}
+
+	if !rootSeen || len(stack) > 0 {
+		return nil, errInvalidSVG
+	}
+	return out.Bytes(), nil
+}
+
+// safeSVGAttr 判断 SVG 属性是否可以保留
+func safeSVGAttr(element string, attr xml.Attr) bool {
+	local := strings.ToLower(attr.Name.Local)
+	value := strings.TrimSpace(attr.Value)
+
+	// 事件属性
+	if strings.HasPrefix(local, "on") {
+		return false
+	}
+	// href 与 xlink:href 只允许指向文档内部，<image> 可内嵌位图
+	if local == "href" || local == "src" {
+		if strings.HasPrefix(value, "#") {
+			return true
+		}
+		return strings.EqualFold(element, "image") && svgDataImagePattern.MatchString(value)
+	}
+	// 去除空白后检查脚本协议，防止 java\nscript: 之类的写法
+	compact := strings.ToLower(strings.Join(strings.Fields(value), ""))
+	if strings.Contains(compact, "javascript:") || strings.Contains(compact, "vbscript:") {
+		return false
+	}
+	return safeSVGStyle(value)
+}
+
+// safeSVGStyle 判断样式文本或属性值中是否只引用文档内部资源（url(#id)），且不含 @import 与 image-set()。
+// CSS 转义（如 u\72l(）可以绕过上述匹配，含反斜杠的内容一律视为不安全
+func safeSVGStyle(value string) bool {
+	if strings.Contains(value, `\`) {
+		return false
+	}
+	lower := strings.ToLower(value)
+	if strings.Contains(lower, "@import") || strings.Contains(lower, "image-set(") {
+		return false
+	}
+	for _, match := range svgCSSURLPattern.FindAllStringSubmatch(value, -1) {
+		if !strings.HasPrefix(match[1], "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// svgName 还原带前缀的元素或属性名（RawToken 不解析命名空间，Space 为原始前缀）
+func svgName(name xml.Name) string {
+	if name.Space != "" {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
@@ -0,0 +1,174 @@
+package handlers
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeSVGRemovesUnsafeContent(t *testing.T) {
+	tests := []struct {
+		name   string
+		input  string
+		absent []string
+	}{
+		{
+			name:   "script 元素",
+			input:  `<svg xmlns="http://www.w3.org/2000/svg"><script>alert(1)</script><rect width="1"/></svg>`,
+			absent: []string{"script", "alert"},
+		},
+		{
+			name:   "带前缀的 svg:script",
+			input:  `<svg:svg xmlns:svg="http://www.w3.org/2000/svg"><svg:script>alert(1)</svg:script></svg:svg>`,
+			absent: []string{"script", "alert"},
+		},
+		{
+			name:   "大写的 SCRIPT 与 foreignObject",
+			input:  `<svg><SCRIPT>alert(1)</SCRIPT><foreignObject><iframe src="https://evil.example"/></foreignObject></svg>`,
+			absent: []string{"alert", "iframe", "evil"},
+		},
+		{
+			name:   "on* 事件属性",
+			input:  `<svg onload="alert(1)"><rect ONCLICK="alert(2)" onMouseOver="alert(3)" width="1"/></svg>`,
+			absent: []string{"alert", "onload", "ONCLICK", "onMouseOver"},
+		},
+		{
+			name:   "xlink:href 使用 javascript:",
+			input:  `<svg xmlns:xlink="http://www.w3.org/1999/xlink"><a xlink:href="javascript:alert(1)"><text>x</text></a></svg>`,
+			absent: []string{"javascript", "href"},
+		},
+		{
+			name:   "实体编码的协议",
+			input:  `<svg><a href="&#106;avascript&#58;alert(1)"><text>x</text></a><rect fill="&#x6a;ava&#x73;cript:alert(2)"/></svg>`,
+			absent: []string{"avascript", "alert"},
+		},
+		{
+			name:   "属性中换行分隔的协议",
+			input:  "<svg><rect fill=\"java\nscript:alert(1)\"/></svg>",
+			absent: []string{"alert"},
+		},
+		{
+			name:   "外部 href",
+			input:  `<svg><use href="https://evil.example/sprite.svg#icon"/><image href="https://evil.example/a.png"/></svg>`,
+			absent: []string{"evil.example"},
+		},
+		{
+			name:   "style 中的 @import",
+			input:  `<svg><style>@import url(https://evil.example/a.css);</style></svg>`,
+			absent: []string{"@import", "evil.example"},
+		},
+		{
+			name:   "style 中外部的 url()",
+			input:  `<svg><style>rect { fill: url( "https://evil.example/a.png" ) }</style><rect style="background:url(//evil.example/b.png)"/></svg>`,
+			absent: []string{"evil.example"},
+		},
+		{
+			name:   "CSS 转义的 url()",
+			input:  `<svg><style>rect { fill: u\72l(https://evil.example/a.png) }</style><rect style="fill:\75rl(https://evil.example/b.png)"/></svg>`,
+			absent: []string{"evil.example"},
+		},
+		{
+			name:   "CSS 转义的 @import",
+			input:  `<svg><style>@\69mport "https://evil.example/a.css";</style></svg>`,
+			absent: []string{"evil.example"},
+		},
+		{
+			name:   "实体编码的 url()",
+			input:  `<svg><style>rect { fill: &#117;rl(https://evil.example/a.png) }</style></svg>`,
+			absent: []string{"evil.example"},
+		},
+		{
+			name:   "CDATA 拆分的 @import",
+			input:  `<svg><style>@im<![CDATA[port 'http://evil.example/x.css';]]></style></svg>`,
+			absent: []string{"port", "evil.example"},
+		},
+		{
+			name:   "CDATA 拆分的 url()",
+			input:  `<svg><style>rect { fill: u<![CDATA[rl(http://evil.example/x)]]> }</style></svg>`,
+			absent: []string{"evil.example"},
+		},
+		{
+			name:   "注释拆分的 @import",
+			input:  `<svg><style>@im<!-- x -->port 'http://evil.example/x.css';</style></svg>`,
+			absent: []string{"evil.example"},
+		},
+		{
+			name:   "DOCTYPE 声明的实体",
+			input:  `<?xml version="1.0"?><!DOCTYPE svg [<!ENTITY xxe SYSTEM "file:///etc/passwd">]><svg><text>x</text></svg>`,
+			absent: []string{"DOCTYPE", "ENTITY", "passwd"},
+		},
+		{
+			name:   "xml-stylesheet 处理指令",
+			input:  `<?xml-stylesheet href="https://evil.example/a.css"?><svg/>`,
+			absent: []string{"evil.example"},
+		},
+		{
+			name:   "动画改写属性",
+			input:  `<svg><a><set attributeName="href" to="javascript:alert(1)"/><animate attributeName="href" values="javascript:alert(2)"/><text>x</text></a></svg>`,
+			absent: []string{"alert", "set", "animate"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := sanitizeSVG([]byte(tt.input))
+			if err != nil {
+				t.Fatal(err)
+			}
+			for _, s := range tt.absent {
+				if strings.Contains(string(out), s) {
+					t.Errorf("结果中仍包含 %q: %s", s, out)
+				}
+			}
+		})
+	}
+}
+
+func TestSanitizeSVGRejectsInvalidDocuments(t *testing.T) {
+	for name, input := range map[string]string{
+		"根元素不是 svg":  `<html><script>alert(1)</script></html>`,
+		"根元素为 xhtml": `<div xmlns="http://www.w3.org/1999/xhtml"><svg/></div>`,
+		"多个根元素":      `<svg/><svg/>`,
+		"根元素外的文本":    `<svg/>alert(1)`,
+		"未闭合的元素":     `<svg><g></svg>`,
+		"不是 XML":     `GIF89a`,
+		"空文档":        ``,
+	} {
+		t.Run(name, func(t *testing.T) {
+			if out, err := sanitizeSVG([]byte(input)); !errors.Is(err, errInvalidSVG) {
+				t.Fatalf("sanitizeSVG = %s, %v，want errInvalidSVG", out, err)
+			}
+		})
+	}
+}
+
+func TestSanitizeSVGKeepsSafeContent(t *testing.T) {
+	input := `<?xml version="1.0" encoding="UTF-8"?>
+<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink" viewBox="0 0 24 24">
+<defs><linearGradient id="g"><stop offset="0" stop-color="#fff"/></linearGradient><path id="p" d="M0 0h24v24H0z"/></defs>
+<style>.a { fill: url(#g); }</style>
+<use xlink:href="#p" class="a"/>
+<rect style="fill: url('#g')" width="24" height="24"/>
+<image href="data:image/png;base64,iVBORw0KGgo="/>
+<text>A &amp; B</text>
+</svg>`
+
+	out, err := sanitizeSVG([]byte(input))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{
+		`<?xml version="1.0" encoding="UTF-8"?>`,
+		`viewBox="0 0 24 24"`,
+		`xmlns:xlink="http://www.w3.org/1999/xlink"`,
+		`.a { fill: url(#g); }`,
+		`xlink:href="#p"`,
+		`style="fill: url('#g')"`,
+		`href="data:image/png;base64,iVBORw0KGgo="`,
+		`<text>A &amp; B</text>`,
+	} {
+		if !strings.Contains(string(out), s) {
+			t.Errorf("结果中缺少 %q: %s", s, out)
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"navdesk/auth"
+	"navdesk/middleware"
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxTokenExpiresInDays 令牌有效期上限
+const maxTokenExpiresInDays = 3650
+
+// TokensHandler API 令牌处理器
+type TokensHandler struct {
+	tokens *storage.Tokens
+}
+
+// NewTokensHandler 创建 API 令牌处理器
+func NewTokensHandler(tokens *storage.Tokens) *TokensHandler {
+	return &TokensHandler{
+		tokens: tokens,
+	}
+}
+
+// GetTokens 获取令牌列表，管理员可以看到所有用户的令牌
+func (h *TokensHandler) GetTokens(c *gin.Context) {
+	user := middleware.CurrentUser(c)
+	username := user.Username
+	if user.Role == models.RoleAdmin {
+		username = ""
+	}
+
+	tokens, err := h.tokens.List(username)
+	if err != nil {
+		log.Printf("读取令牌失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "获取令牌失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    tokens,
+	})
+}
+
+// CreateToken 创建令牌，权限范围不能超出当前用户的角色
+func (h *TokensHandler) CreateToken(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	var req models.CreateTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "令牌名称和权限范围不能为空",
+		})
+		return
+	}
+
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > 50 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "令牌名称不能为空且不能超过 50 个字符",
+		})
+		return
+	}
+
+	scopes := []string{}
+	seen := map[string]bool{}
+	for _, scope := range req.Scopes {
+		if !auth.ValidScope(scope) {
+			c.JSON(http.StatusBadRequest, models.APIResponse{
+				Success: false,
+				Message: "无效的权限范围: " + scope,
+			})
+			return
+		}
+		if !seen[scope] {
+			seen[scope] = true
+			scopes = append(scopes, scope)
+		}
+	}
+	if len(scopes) == 0 {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "令牌名称和权限范围不能为空",
+		})
+		return
+	}
+
+	user := middleware.CurrentUser(c)
+	if auth.ScopesLevel(scopes) > auth.RoleLevel(user.Role) {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Message: "权限范围超出当前角色",
+		})
+		return
+	}
+
+	if req.ExpiresInDays < 0 || req.ExpiresInDays > maxTokenExpiresInDays {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "有效期需在 0-3650 天之间（0 表示永不过期）",
+		})
+		return
+	}
+	var expiresAt *time.Time
+	if req.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		expiresAt = &expires
+	}
+
+	token, raw, err := h.tokens.Create(user.Username, name, scopes, expiresAt)
+	if err != nil {
+		log.Printf("创建令牌失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "创建令牌失败",
+		})
+		return
+	}
+
+	log.Printf("令牌创建成功: %s (%s) 权限: %s - 用户: %s", token.Name, token.Prefix, strings.Join(scopes, ","), user.Username)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "令牌创建成功，请立即复制保存，之后将无法再次查看",
+		Data: models.CreateTokenResponse{
+			APIToken: token,
+			Token:    raw,
+		},
+	})
+}
+
+// RevokeToken 吊销令牌，管理员可以吊销任意用户的令牌
+func (h *TokensHandler) RevokeToken(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+
+	id := c.Param("id")
+	user := middleware.CurrentUser(c)
+
+	token, err := h.tokens.Get(id)
+	if err == nil && token.Username != user.Username && user.Role != models.RoleAdmin {
+		err = storage.ErrTokenNotFound
+	}
+	if err == nil {
+		err = h.tokens.Revoke(id)
+	}
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Message: "令牌不存在",
+			})
+			return
+		}
+		log.Printf("吊销令牌失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "吊销令牌失败",
+		})
+		return
+	}
+
+	log.Printf("令牌已吊销: %s (%s) 所属用户: %s - 操作者: %s", token.Name, token.Prefix, token.Username, user.Username)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "令牌已吊销",
+	})
+}
+
+// requireSession 令牌、两步验证与登录会话只能通过登录会话管理，防止泄露的令牌为自己续期、扩权或关闭两步验证
+func requireSession(c *gin.Context) bool {
+	if middleware.APIToken(c) != nil {
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Message: "该操作不能使用 API 令牌，请登录后台操作",
+		})
+		return false
+	}
+	return true
+}
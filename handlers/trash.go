@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TrashHandler 回收站处理器
+type TrashHandler struct {
+	trash *storage.Trash
+}
+
+// NewTrashHandler 创建回收站处理器
+func NewTrashHandler(trash *storage.Trash) *TrashHandler {
+	return &TrashHandler{
+		trash: trash,
+	}
+}
+
+// GetTrash 获取回收站条目
+func (h *TrashHandler) GetTrash(c *gin.Context) {
+	items, err := h.trash.List()
+	if err != nil {
+		log.Printf("读取回收站失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "获取回收站失败",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    items,
+	})
+}
+
+// RestoreTrashItem 恢复回收站条目
+func (h *TrashHandler) RestoreTrashItem(c *gin.Context) {
+	id := c.Param("id")
+
+	item, err := h.trash.Restore(id)
+	if err != nil {
+		switch {
+		case errors.Is(err, storage.ErrTrashItemNotFound):
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Message: "回收站条目不存在",
+			})
+		case errors.Is(err, storage.ErrRestoreConflict):
+			c.JSON(http.StatusConflict, models.APIResponse{
+				Success: false,
+				Message: strings.TrimPrefix(err.Error(), storage.ErrRestoreConflict.Error()+": "),
+			})
+		default:
+			log.Printf("回收站恢复失败: %s - %v", id, err)
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "恢复失败",
+			})
+		}
+		return
+	}
+
+	log.Printf("回收站条目已恢复: %s (%s) - 用户: %s", item.Name, item.Type, currentUsername(c))
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "恢复成功",
+		Data:    item,
+	})
+}
+
+// PurgeTrashItem 永久删除回收站条目
+func (h *TrashHandler) PurgeTrashItem(c *gin.Context) {
+	id := c.Param("id")
+
+	if err := h.trash.Purge(id); err != nil {
+		if errors.Is(err, storage.ErrTrashItemNotFound) {
+			c.JSON(http.StatusNotFound, models.APIResponse{
+				Success: false,
+				Message: "回收站条目不存在",
+			})
+			return
+		}
+		log.Printf("回收站条目永久删除失败: %s - %v", id, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "永久删除失败",
+		})
+		return
+	}
+
+	log.Printf("回收站条目已永久删除: %s - 用户: %s", id, currentUsername(c))
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "已永久删除",
+	})
+}
+
+// EmptyTrash 清空回收站
+func (h *TrashHandler) EmptyTrash(c *gin.Context) {
+	count, err := h.trash.PurgeAll()
+	if err != nil {
+		log.Printf("清空回收站失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "清空回收站失败",
+		})
+		return
+	}
+
+	log.Printf("回收站已清空: %d 个条目 - 用户: %s", count, currentUsername(c))
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "回收站已清空",
+	})
+}
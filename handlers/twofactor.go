@@ -0,0 +1,311 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image/png"
+	"log"
+	"net/http"
+	"time"
+
+	"navdesk/auth"
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/boombuler/barcode"
+	"github.com/boombuler/barcode/qr"
+	"github.com/gin-gonic/gin"
+)
+
+// qrCodeSize 两步验证二维码图片的边长（像素）
+const qrCodeSize = 240
+
+// TwoFactorHandler 两步验证绑定与管理处理器，只处理当前登录用户自己的两步验证
+type TwoFactorHandler struct {
+	storage storage.Store
+	issuer  string
+}
+
+// NewTwoFactorHandler 创建两步验证处理器，issuer 显示在验证器应用中
+func NewTwoFactorHandler(storage storage.Store, issuer string) *TwoFactorHandler {
+	return &TwoFactorHandler{
+		storage: storage,
+		issuer:  issuer,
+	}
+}
+
+// GetStatus 获取当前用户的两步验证状态
+func (h *TwoFactorHandler) GetStatus(c *gin.Context) {
+	_, user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data: map[string]interface{}{
+			"enabled":                user.TOTPEnabled,
+			"recoveryCodesRemaining": len(user.RecoveryCodes),
+		},
+	})
+}
+
+// Setup 开始绑定：生成新密钥并返回配置 URI 与二维码，需调用 Enable 确认后才生效
+func (h *TwoFactorHandler) Setup(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+	users, user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "已启用两步验证，如需更换验证器请先关闭",
+		})
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		h.fail(c, "生成密钥失败", err)
+		return
+	}
+	uri := auth.TOTPURI(h.issuer, user.Username, secret)
+	qrCode, err := qrCodeDataURI(uri)
+	if err != nil {
+		h.fail(c, "生成二维码失败", err)
+		return
+	}
+
+	user.TOTPSecret = secret
+	user.TOTPLastStep = 0
+	if !h.save(c, users, user) {
+		return
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "请使用验证器应用扫描二维码，并输入生成的验证码完成绑定",
+		Data: models.TwoFactorSetupResponse{
+			Secret: secret,
+			URI:    uri,
+			QRCode: qrCode,
+		},
+	})
+}
+
+// Enable 输入验证码确认绑定，成功后返回一组恢复码（只显示这一次）
+func (h *TwoFactorHandler) Enable(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+	var req models.TwoFactorCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "验证码不能为空",
+		})
+		return
+	}
+
+	users, user, ok := h.currentUser(c)
+	if !ok {
+		return
+	}
+
+	if user.TOTPEnabled {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "已启用两步验证",
+		})
+		return
+	}
+	if user.TOTPSecret == "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "请先获取二维码",
+		})
+		return
+	}
+
+	step, valid := auth.ValidateTOTP(user.TOTPSecret, req.Code, time.Now(), 0)
+	if !valid {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "验证码错误，请确认手机时间准确后重试",
+		})
+		return
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		h.fail(c, "生成恢复码失败", err)
+		return
+	}
+
+	user.TOTPEnabled = true
+	user.TOTPLastStep = step
+	user.RecoveryCodes = hashes
+	if !h.save(c, users, user) {
+		return
+	}
+
+	log.Printf("用户 %s 已启用两步验证", user.Username)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "两步验证已启用，请妥善保存恢复码，每个恢复码只能使用一次",
+		Data: map[string]interface{}{
+			"recoveryCodes": codes,
+		},
+	})
+}
+
+// Disable 输入密码确认后关闭两步验证
+func (h *TwoFactorHandler) Disable(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+	users, user, ok := h.confirmPassword(c)
+	if !ok {
+		return
+	}
+
+	user.TOTPSecret = ""
+	user.TOTPEnabled = false
+	user.TOTPLastStep = 0
+	user.RecoveryCodes = nil
+	if !h.save(c, users, user) {
+		return
+	}
+
+	log.Printf("用户 %s 已关闭两步验证", user.Username)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "两步验证已关闭",
+	})
+}
+
+// RegenerateRecoveryCodes 输入密码确认后重新生成恢复码，旧的恢复码全部失效
+func (h *TwoFactorHandler) RegenerateRecoveryCodes(c *gin.Context) {
+	if !requireSession(c) {
+		return
+	}
+	users, user, ok := h.confirmPassword(c)
+	if !ok {
+		return
+	}
+
+	if !user.TOTPEnabled {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "尚未启用两步验证",
+		})
+		return
+	}
+
+	codes, hashes, err := auth.GenerateRecoveryCodes()
+	if err != nil {
+		h.fail(c, "生成恢复码失败", err)
+		return
+	}
+	user.RecoveryCodes = hashes
+	if !h.save(c, users, user) {
+		return
+	}
+
+	log.Printf("用户 %s 重新生成了恢复码", user.Username)
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "恢复码已重新生成，旧的恢复码已失效",
+		Data: map[string]interface{}{
+			"recoveryCodes": codes,
+		},
+	})
+}
+
+// currentUser 读取当前登录用户，失败时已写入响应
+func (h *TwoFactorHandler) currentUser(c *gin.Context) (map[string]models.User, models.User, bool) {
+	users, err := h.storage.GetUsers()
+	if err != nil {
+		h.fail(c, "获取用户失败", err)
+		return nil, models.User{}, false
+	}
+
+	user, exists := users[currentUsername(c)]
+	if !exists {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "用户不存在",
+		})
+		return nil, models.User{}, false
+	}
+	return users, user, true
+}
+
+// confirmPassword 读取当前登录用户并校验请求中的密码，失败时已写入响应
+func (h *TwoFactorHandler) confirmPassword(c *gin.Context) (map[string]models.User, models.User, bool) {
+	var req models.TwoFactorPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "请输入密码",
+		})
+		return nil, models.User{}, false
+	}
+
+	users, user, ok := h.currentUser(c)
+	if !ok {
+		return nil, models.User{}, false
+	}
+	if valid, _ := auth.CheckPassword(user.Password, req.Password); !valid {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "密码错误",
+		})
+		return nil, models.User{}, false
+	}
+	return users, user, true
+}
+
+// save 保存用户，失败时已写入响应
+func (h *TwoFactorHandler) save(c *gin.Context, users map[string]models.User, user models.User) bool {
+	user.UpdatedAt = time.Now()
+	users[user.Username] = user
+	if err := h.storage.SaveUsers(users); err != nil {
+		h.fail(c, "保存用户失败", err)
+		return false
+	}
+	return true
+}
+
+func (h *TwoFactorHandler) fail(c *gin.Context, message string, err error) {
+	log.Printf("两步验证: %s - %v", message, err)
+	c.JSON(http.StatusInternalServerError, models.APIResponse{
+		Success: false,
+		Message: message,
+	})
+}
+
+// qrCodeDataURI 将内容编码为二维码，返回 PNG 格式的 data URI
+func qrCodeDataURI(content string) (string, error) {
+	code, err := qr.Encode(content, qr.M, qr.Auto)
+	if err != nil {
+		return "", err
+	}
+	code, err = barcode.Scale(code, qrCodeSize, qrCodeSize)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, code); err != nil {
+		return "", err
+	}
+	return "data:image/png;base64," + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -19,16 +20,69 @@ import (
 
 // UploadHandler 上传处理器
 type UploadHandler struct {
-	storage *storage.Storage
+	storage storage.Store
 }
 
 // NewUploadHandler 创建上传处理器
-func NewUploadHandler(storage *storage.Storage) *UploadHandler {
+func NewUploadHandler(storage storage.Store) *UploadHandler {
 	return &UploadHandler{
 		storage: storage,
 	}
 }
 
+// maxUploadSize 上传文件大小上限
+const maxUploadSize = 2 << 20 // 2MB
+
+// errUploadTooLarge 文件超过 maxUploadSize
+var errUploadTooLarge = errors.New("文件大小不能超过 2MB")
+
+// imageContentTypes 各扩展名允许的文件内容类型（http.DetectContentType 的识别结果），
+// 部分 .ico 实际是 PNG 格式，浏览器同样支持
+var imageContentTypes = map[string][]string{
+	".jpeg": {"image/jpeg"},
+	".jpg":  {"image/jpeg"},
+	".png":  {"image/png"},
+	".gif":  {"image/gif"},
+	".webp": {"image/webp"},
+	".ico":  {"image/x-icon", "image/png"},
+}
+
+// errImageContentMismatch 文件内容与扩展名不符
+var errImageContentMismatch = errors.New("文件内容与扩展名不符")
+
+// readImageFile 读取上传的图片并按内容校验：位图检查文件头与扩展名是否一致，SVG 清理后返回
+func readImageFile(file io.Reader, filename string) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(file, maxUploadSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxUploadSize {
+		return nil, errUploadTooLarge
+	}
+
+	ext := strings.ToLower(filepath.Ext(filename))
+	if ext == ".svg" {
+		return sanitizeSVG(data)
+	}
+
+	contentType := http.DetectContentType(data)
+	for _, allowed := range imageContentTypes[ext] {
+		if contentType == allowed {
+			return data, nil
+		}
+	}
+	return nil, errImageContentMismatch
+}
+
+// imageErrorMessage 将图片校验错误转换为提示信息
+func imageErrorMessage(err error) string {
+	switch {
+	case errors.Is(err, errUploadTooLarge), errors.Is(err, errImageContentMismatch), errors.Is(err, errInvalidSVG):
+		return err.Error()
+	}
+	return "文件读取失败"
+}
+
 // 检查文件类型
 func (h *UploadHandler) isValidImageFile(filename string) bool {
 	allowedExtensions := []string{".jpeg", ".jpg", ".png", ".gif", ".svg", ".ico", ".webp"}
@@ -45,7 +99,7 @@ func (h *UploadHandler) isValidImageFile(filename string) bool {
 // UploadIcon 图标上传接口
 func (h *UploadHandler) UploadIcon(c *gin.Context) {
 	// 解析multipart表单
-	err := c.Request.ParseMultipartForm(2 << 20) // 2MB
+	err := c.Request.ParseMultipartForm(maxUploadSize)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
@@ -65,7 +119,7 @@ func (h *UploadHandler) UploadIcon(c *gin.Context) {
 	defer file.Close()
 
 	// 检查文件大小
-	if header.Size > 2*1024*1024 { // 2MB
+	if header.Size > maxUploadSize {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
 			Message: "文件大小不能超过 2MB",
@@ -82,6 +136,17 @@ func (h *UploadHandler) UploadIcon(c *gin.Context) {
 		return
 	}
 
+	// 按文件内容校验，SVG 清理脚本与外部引用
+	content, err := readImageFile(file, header.Filename)
+	if err != nil {
+		log.Printf("图片内容校验失败: %s - %v", header.Filename, err)
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: imageErrorMessage(err),
+		})
+		return
+	}
+
 	categoryId := c.PostForm("category")
 	if categoryId == "" {
 		categoryId = "common"
@@ -106,45 +171,44 @@ func (h *UploadHandler) UploadIcon(c *gin.Context) {
 		}
 	}
 
-	// 如果有旧图标，先删除旧图标文件
-	if oldIcon != "" && strings.HasPrefix(oldIcon, "/uploads/") {
-		oldIconPath := filepath.Join(h.storage.GetDataPath(), strings.TrimPrefix(oldIcon, "/"))
-		if _, err := os.Stat(oldIconPath); err == nil {
-			os.Remove(oldIconPath)
-			log.Printf("旧图标文件删除成功: %s", oldIcon)
-		}
-	}
-
 	// 生成新的文件名
 	timestamp := time.Now().UnixNano()
 	randomStr := strings.ReplaceAll(uuid.New().String()[:8], "-", "")
 	ext := filepath.Ext(header.Filename)
 	filename := fmt.Sprintf("icon_%d_%s%s", timestamp, randomStr, ext)
 
-	// 创建目标目录
-	targetDir := filepath.Join(h.storage.GetUploadsPath(), uploadDir)
-	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
-		os.MkdirAll(targetDir, 0755)
-	}
-
-	// 保存文件
-	targetFilePath := filepath.Join(targetDir, filename)
-	targetFile, err := os.Create(targetFilePath)
+	targetFilePath, err := storage.ResolveUploadPath(h.storage, uploadDir+"/"+filename)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.APIResponse{
+		log.Printf("图标上传失败: 分类 %s 的上传目录无效: %q", categoryId, uploadDir)
+		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
-			Message: "文件保存失败",
+			Message: "分类的上传目录无效",
 		})
 		return
 	}
-	defer targetFile.Close()
 
-	_, err = io.Copy(targetFile, file)
-	if err != nil {
+	// 如果有旧图标，先删除旧图标文件（只删除上传目录内的普通文件）
+	if oldIcon != "" && strings.HasPrefix(oldIcon, "/uploads/") {
+		if oldIconPath, err := storage.ResolveUploadURL(h.storage, oldIcon); err != nil {
+			log.Printf("拒绝删除上传目录以外的文件: %q", oldIcon)
+		} else if info, err := os.Stat(oldIconPath); err == nil && info.Mode().IsRegular() {
+			os.Remove(oldIconPath)
+			log.Printf("旧图标文件删除成功: %s", oldIcon)
+		}
+	}
+
+	// 创建目标目录
+	targetDir := filepath.Dir(targetFilePath)
+	if _, err := os.Stat(targetDir); os.IsNotExist(err) {
+		os.MkdirAll(targetDir, 0755)
+	}
+
+	// 保存文件
+	if err := os.WriteFile(targetFilePath, content, 0644); err != nil {
 		os.Remove(targetFilePath) // 清理失败的文件
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Message: "文件处理失败",
+			Message: "文件保存失败",
 		})
 		return
 	}
@@ -154,7 +218,7 @@ func (h *UploadHandler) UploadIcon(c *gin.Context) {
 	log.Printf("图标上传成功: %s → %s (%dKB) - 分类: %s%s",
 		header.Filename,
 		fileUrl,
-		header.Size/1024,
+		len(content)/1024,
 		categoryId,
 		func() string {
 			if oldIcon != "" {
@@ -171,7 +235,7 @@ func (h *UploadHandler) UploadIcon(c *gin.Context) {
 			URL:          fileUrl,
 			Filename:     filename,
 			OriginalName: header.Filename,
-			Size:         header.Size,
+			Size:         int64(len(content)),
 		},
 	})
 }
@@ -179,7 +243,7 @@ func (h *UploadHandler) UploadIcon(c *gin.Context) {
 // UploadFavicon 网站图标上传接口
 func (h *UploadHandler) UploadFavicon(c *gin.Context) {
 	// 解析multipart表单
-	err := c.Request.ParseMultipartForm(2 << 20) // 2MB
+	err := c.Request.ParseMultipartForm(maxUploadSize)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
@@ -199,7 +263,7 @@ func (h *UploadHandler) UploadFavicon(c *gin.Context) {
 	defer file.Close()
 
 	// 检查文件大小
-	if header.Size > 2*1024*1024 { // 2MB
+	if header.Size > maxUploadSize {
 		c.JSON(http.StatusBadRequest, models.APIResponse{
 			Success: false,
 			Message: "文件大小不能超过 2MB",
@@ -216,37 +280,46 @@ func (h *UploadHandler) UploadFavicon(c *gin.Context) {
 		return
 	}
 
-	// 确保favicon目录存在
-	faviconDir := filepath.Join(h.storage.GetUploadsPath(), "favicon")
-	if _, err := os.Stat(faviconDir); os.IsNotExist(err) {
-		os.MkdirAll(faviconDir, 0755)
+	// 按文件内容校验，SVG 清理脚本与外部引用
+	content, err := readImageFile(file, header.Filename)
+	if err != nil {
+		log.Printf("图片内容校验失败: %s - %v", header.Filename, err)
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: imageErrorMessage(err),
+		})
+		return
 	}
 
 	// 保存为favicon.ico
-	targetFilePath := filepath.Join(faviconDir, "favicon.ico")
-	targetFile, err := os.Create(targetFilePath)
+	targetFilePath, err := storage.ResolveUploadPath(h.storage, "favicon/favicon.ico")
 	if err != nil {
+		log.Printf("网站图标保存失败: %v", err)
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
 			Message: "文件保存失败",
 		})
 		return
 	}
-	defer targetFile.Close()
 
-	_, err = io.Copy(targetFile, file)
-	if err != nil {
+	// 确保favicon目录存在
+	faviconDir := filepath.Dir(targetFilePath)
+	if _, err := os.Stat(faviconDir); os.IsNotExist(err) {
+		os.MkdirAll(faviconDir, 0755)
+	}
+
+	if err := os.WriteFile(targetFilePath, content, 0644); err != nil {
 		os.Remove(targetFilePath) // 清理失败的文件
 		c.JSON(http.StatusInternalServerError, models.APIResponse{
 			Success: false,
-			Message: "文件处理失败",
+			Message: "文件保存失败",
 		})
 		return
 	}
 
 	log.Printf("网站图标更新成功: %s (%dKB)",
 		header.Filename,
-		header.Size/1024)
+		len(content)/1024)
 
 	c.JSON(http.StatusOK, map[string]interface{}{
 		"success": true,
@@ -256,7 +329,7 @@ func (h *UploadHandler) UploadFavicon(c *gin.Context) {
 			URL:          "/favicon.ico",
 			Filename:     "favicon.ico",
 			OriginalName: header.Filename,
-			Size:         header.Size,
+			Size:         int64(len(content)),
 		},
 	})
 }
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+// newTestStore 在临时目录中创建带有 users.json 与一个分类的 JSON 存储
+func newTestStore(t *testing.T) *storage.JSONStore {
+	t.Helper()
+	dataPath := t.TempDir()
+	files := map[string]string{
+		"users.json":      `{"admin": {"username": "admin", "role": "admin"}}`,
+		"categories.json": `[{"id": "cat_common", "name": "常用", "icon": "⭐", "uploadDir": "common", "version": 1}]`,
+		"bookmarks.json":  `[]`,
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dataPath, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Join(dataPath, "uploads", "common"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	return storage.NewJSONStore(dataPath)
+}
+
+func newTestRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(sessions.Sessions("navdesk_session", cookie.NewStore([]byte("test"))))
+	return r
+}
+
+func uploadIconRequest(t *testing.T, fields map[string]string) *http.Request {
+	t.Helper()
+	var body bytes.Buffer
+	form := multipart.NewWriter(&body)
+	for key, value := range fields {
+		form.WriteField(key, value)
+	}
+	part, err := form.CreateFormFile("icon", "icon.png")
+	if err != nil {
+		t.Fatal(err)
+	}
+	part.Write([]byte("\x89PNG\r\n\x1a\n"))
+	form.Close()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/upload", &body)
+	req.Header.Set("Content-Type", form.FormDataContentType())
+	return req
+}
+
+func TestUploadIconDoesNotDeleteOutsideUploads(t *testing.T) {
+	store := newTestStore(t)
+	r := newTestRouter()
+	r.POST("/api/upload", NewUploadHandler(store).UploadIcon)
+
+	for _, oldIcon := range []string{
+		"/uploads/../users.json",
+		"/uploads/common/../../users.json",
+		"/uploads/..",
+		"/uploads/common",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, uploadIconRequest(t, map[string]string{"category": "cat_common", "oldIcon": oldIcon}))
+		if w.Code != http.StatusOK {
+			t.Fatalf("oldIcon=%q: status %d, body %s", oldIcon, w.Code, w.Body)
+		}
+		if _, err := os.Stat(filepath.Join(store.GetDataPath(), "users.json")); err != nil {
+			t.Fatalf("oldIcon=%q: users.json 被删除: %v", oldIcon, err)
+		}
+		if _, err := os.Stat(filepath.Join(store.GetUploadsPath(), "common")); err != nil {
+			t.Fatalf("oldIcon=%q: 上传目录被删除: %v", oldIcon, err)
+		}
+	}
+}
+
+func TestUploadIconDeletesOldIconInsideUploads(t *testing.T) {
+	store := newTestStore(t)
+	r := newTestRouter()
+	r.POST("/api/upload", NewUploadHandler(store).UploadIcon)
+
+	oldPath := filepath.Join(store.GetUploadsPath(), "common", "old.png")
+	if err := os.WriteFile(oldPath, []byte("old"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, uploadIconRequest(t, map[string]string{"category": "cat_common", "oldIcon": "/uploads/common/old.png"}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Fatalf("旧图标未删除: %v", err)
+	}
+}
+
+func TestUploadIconRejectsUnsafeCategoryDir(t *testing.T) {
+	store := newTestStore(t)
+	categories := []models.Category{{ID: "cat_evil", Name: "evil", UploadDir: "../../escape", Version: 1}}
+	if err := store.SaveCategories(categories); err != nil {
+		t.Fatal(err)
+	}
+	r := newTestRouter()
+	r.POST("/api/upload", NewUploadHandler(store).UploadIcon)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, uploadIconRequest(t, map[string]string{"category": "cat_evil"}))
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("status %d, want 400", w.Code)
+	}
+	if _, err := os.Stat(filepath.Join(store.GetUploadsPath(), "..", "..", "escape")); !os.IsNotExist(err) {
+		t.Fatalf("在上传目录以外创建了目录: %v", err)
+	}
+}
+
+func TestCreateCategoryRejectsUnsafeUploadDir(t *testing.T) {
+	store := newTestStore(t)
+	r := newTestRouter()
+	r.POST("/api/categories", NewCategoriesHandler(store, nil, nil, nil).CreateCategory)
+
+	for _, dir := range []string{"..", "../escape", "common/../../escape", "/tmp/escape", "a/b", ".hidden", "favicon"} {
+		body, _ := json.Marshal(models.CreateCategoryRequest{Name: "分类 " + dir, Icon: "📁", UploadDir: dir})
+		req := httptest.NewRequest(http.MethodPost, "/api/categories", bytes.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("uploadDir=%q: status %d, want 400", dir, w.Code)
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(store.GetDataPath(), "escape")); !os.IsNotExist(err) {
+		t.Fatalf("在上传目录以外创建了目录: %v", err)
+	}
+	categories, err := store.GetCategories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(categories) != 1 {
+		t.Fatalf("保存了 %d 个分类，want 1", len(categories))
+	}
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+
+	"navdesk/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// DefaultURLSchemes 默认允许的书签网址协议
+var DefaultURLSchemes = []string{"http", "https"}
+
+// schemePattern 协议名格式（RFC 3986）
+var schemePattern = regexp.MustCompile(`^[a-z][a-z0-9+.-]*$`)
+
+// forbiddenSchemes 可以执行脚本或内嵌内容的协议，即使配置了也不允许
+var forbiddenSchemes = map[string]bool{
+	"javascript": true,
+	"vbscript":   true,
+	"data":       true,
+	"blob":       true,
+}
+
+// URLPolicy 书签网址与图标地址的校验规则
+type URLPolicy struct {
+	schemes map[string]bool
+	names   []string
+}
+
+// NewURLPolicy 按允许的协议列表创建校验规则，列表为空时使用 DefaultURLSchemes
+func NewURLPolicy(schemes []string) (*URLPolicy, error) {
+	if len(schemes) == 0 {
+		schemes = DefaultURLSchemes
+	}
+
+	policy := &URLPolicy{schemes: make(map[string]bool)}
+	for _, scheme := range schemes {
+		scheme = strings.ToLower(strings.TrimSuffix(strings.TrimSpace(scheme), ":"))
+		if !schemePattern.MatchString(scheme) {
+			return nil, fmt.Errorf("无效的协议名: %q", scheme)
+		}
+		if forbiddenSchemes[scheme] {
+			return nil, fmt.Errorf("不允许的协议: %s", scheme)
+		}
+		if !policy.schemes[scheme] {
+			policy.schemes[scheme] = true
+			policy.names = append(policy.names, scheme)
+		}
+	}
+	return policy, nil
+}
+
+// Schemes 返回允许的协议列表
+func (p *URLPolicy) Schemes() []string {
+	return append([]string(nil), p.names...)
+}
+
+// NormalizeURL 校验并规范化书签网址：去除首尾空白，协议和主机名转为小写。
+// 只接受允许列表中的协议，http 与 https 必须带有主机名
+func (p *URLPolicy) NormalizeURL(raw string) (string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || u.Scheme == "" {
+		return "", fmt.Errorf("网址格式不正确，需要以 %s:// 开头", strings.Join(p.names, "://、"))
+	}
+
+	scheme := strings.ToLower(u.Scheme)
+	if !p.schemes[scheme] {
+		return "", fmt.Errorf("不支持的网址协议 %s:，允许的协议: %s", scheme, strings.Join(p.names, "、"))
+	}
+	u.Scheme = scheme
+	u.Host = strings.ToLower(u.Host)
+
+	if (scheme == "http" || scheme == "https") && u.Host == "" {
+		return "", fmt.Errorf("网址缺少主机名")
+	}
+	if u.Host == "" && u.Opaque == "" && u.Path == "" {
+		return "", fmt.Errorf("网址格式不正确")
+	}
+	return u.String(), nil
+}
+
+// NormalizeIcon 校验并规范化图标地址。留空与 local 原样返回，
+// 其余只接受站内路径（以 / 开头）或 http、https 图片地址，不受书签协议配置影响
+func (p *URLPolicy) NormalizeIcon(raw string) (string, error) {
+	icon := strings.TrimSpace(raw)
+	if icon == "" || strings.EqualFold(icon, "local") {
+		return icon, nil
+	}
+
+	// 图标地址会写入 CSS url()，不允许可能提前结束该表达式的字符。
+	// 在解析前检查原始输入，不依赖序列化时的百分号编码
+	if strings.ContainsAny(icon, "\"'()\\ \t\r\n") {
+		return "", fmt.Errorf("图标地址不能包含引号、括号、反斜杠或空白字符")
+	}
+
+	u, err := url.Parse(icon)
+	if err != nil {
+		return "", fmt.Errorf("图标地址格式不正确")
+	}
+
+	switch {
+	case u.Scheme == "" && u.Host == "" && strings.HasPrefix(u.Path, "/"):
+		// 站内路径，如 /uploads/common/icon.png
+		cleaned := path.Clean(u.Path)
+		if cleaned != u.Path {
+			return "", fmt.Errorf("图标路径不能包含 . 或 .. 等相对路径")
+		}
+	case u.Scheme == "http" || u.Scheme == "https":
+		if u.Host == "" {
+			return "", fmt.Errorf("图标地址缺少主机名")
+		}
+		u.Host = strings.ToLower(u.Host)
+	default:
+		return "", fmt.Errorf("图标只能是 http://、https:// 地址或以 / 开头的站内路径")
+	}
+
+	return u.String(), nil
+}
+
+// normalizeBookmarkLinks 规范化书签的网址与图标，返回逐字段的校验错误
+func (p *URLPolicy) normalizeBookmarkLinks(rawURL, rawIcon string) (string, string, []models.FieldError) {
+	var errs []models.FieldError
+
+	normalizedURL, err := p.NormalizeURL(rawURL)
+	if err != nil {
+		errs = append(errs, models.FieldError{Field: "url", Message: err.Error()})
+	}
+	normalizedIcon, err := p.NormalizeIcon(rawIcon)
+	if err != nil {
+		errs = append(errs, models.FieldError{Field: "icon", Message: err.Error()})
+	}
+	return normalizedURL, normalizedIcon, errs
+}
+
+// validationFailed 返回字段级校验错误，message 汇总所有错误供不识别 errors 的客户端显示
+func validationFailed(c *gin.Context, errs []models.FieldError) {
+	messages := make([]string, 0, len(errs))
+	for _, fieldErr := range errs {
+		messages = append(messages, fieldErr.Message)
+	}
+	c.JSON(http.StatusBadRequest, models.APIResponse{
+		Success: false,
+		Message: strings.Join(messages, "；"),
+		Errors:  errs,
+	})
+}
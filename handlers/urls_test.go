@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"navdesk/models"
+	"navdesk/storage"
+)
+
+func TestNormalizeBookmarkLinksRejectsUnsafeValues(t *testing.T) {
+	policy, err := NewURLPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const validURL = "https://example.com"
+	tests := []struct {
+		name  string
+		url   string
+		icon  string
+		field string
+	}{
+		{name: "javascript: 网址", url: "javascript:alert(1)", field: "url"},
+		{name: "大小写混合的 JaVaScRiPt:", url: "JaVaScRiPt:alert(1)", field: "url"},
+		{name: "前导空格的 javascript:", url: " javascript:alert(1)", field: "url"},
+		{name: "制表符分隔的 java\\tscript:", url: "java\tscript:alert(1)", field: "url"},
+		{name: "vbscript: 网址", url: "vbscript:msgbox(1)", field: "url"},
+		{name: "data:text/html 网址", url: "data:text/html,<script>alert(1)</script>", field: "url"},
+		{name: "blob: 网址", url: "blob:https://example.com/0f3c", field: "url"},
+		{name: "协议相对网址", url: "//evil.example/", field: "url"},
+		{name: "javascript: 图标", url: validURL, icon: "javascript:alert(1)", field: "icon"},
+		{name: "data: 图标", url: validURL, icon: "data:image/svg+xml,<svg onload=alert(1)>", field: "icon"},
+		{name: "协议相对图标", url: validURL, icon: "//evil.example/a.png", field: "icon"},
+		{name: "图标包含单引号", url: validURL, icon: "https://example.com/a.png');background:url('https://evil.example/", field: "icon"},
+		{name: "图标包含双引号", url: validURL, icon: `https://example.com/a.png"onerror="alert(1)`, field: "icon"},
+		{name: "图标包含括号", url: validURL, icon: "/uploads/common/a.png)}body{background:url(/x", field: "icon"},
+		{name: "图标包含反斜杠", url: validURL, icon: `/uploads/common/a.png\29`, field: "icon"},
+		{name: "图标包含空白", url: validURL, icon: "/uploads/common/a b.png", field: "icon"},
+		{name: "图标路径包含 ..", url: validURL, icon: "/uploads/../users.json", field: "icon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, errs := policy.normalizeBookmarkLinks(tt.url, tt.icon)
+			if len(errs) != 1 || errs[0].Field != tt.field || errs[0].Message == "" {
+				t.Fatalf("errs = %+v，want 一个 %s 字段的错误", errs, tt.field)
+			}
+		})
+	}
+}
+
+func TestNormalizeBookmarkLinksAcceptsSafeValues(t *testing.T) {
+	policy, err := NewURLPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url, icon         string
+		wantURL, wantIcon string
+	}{
+		{url: " HTTPS://Example.COM/Path?q=1 ", icon: "", wantURL: "https://example.com/Path?q=1", wantIcon: ""},
+		{url: "http://example.com", icon: "local", wantURL: "http://example.com", wantIcon: "local"},
+		{url: "https://example.com", icon: "/uploads/common/icon.png", wantURL: "https://example.com", wantIcon: "/uploads/common/icon.png"},
+		{url: "https://example.com", icon: "https://CDN.example.com/a.png", wantURL: "https://example.com", wantIcon: "https://cdn.example.com/a.png"},
+	}
+
+	for _, tt := range tests {
+		gotURL, gotIcon, errs := policy.normalizeBookmarkLinks(tt.url, tt.icon)
+		if len(errs) != 0 || gotURL != tt.wantURL || gotIcon != tt.wantIcon {
+			t.Errorf("normalizeBookmarkLinks(%q, %q) = %q, %q, %+v", tt.url, tt.icon, gotURL, gotIcon, errs)
+		}
+	}
+}
+
+func TestNewURLPolicyRejectsScriptSchemes(t *testing.T) {
+	for _, scheme := range []string{"javascript", "JavaScript:", "vbscript", "data", "blob", "not a scheme"} {
+		if _, err := NewURLPolicy([]string{"https", scheme}); err == nil {
+			t.Errorf("NewURLPolicy 接受了 %q", scheme)
+		}
+	}
+}
+
+func TestCreateBookmarkReturnsFieldErrors(t *testing.T) {
+	store := newTestStore(t)
+	r := newTestRouter()
+	policy, err := NewURLPolicy(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := NewBookmarksHandler(store, storage.NewTrash(store, 0), storage.NewHistory(store, 0), policy)
+	r.POST("/api/bookmarks/", handler.CreateBookmark)
+
+	body, _ := json.Marshal(models.Bookmark{
+		Name:     "示例",
+		URL:      "JaVaScRiPt:alert(1)",
+		Icon:     "https://example.com/a.png'",
+		Category: "cat_common",
+	})
+	req := httptest.NewRequest(http.MethodPost, "/api/bookmarks/", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	var resp models.APIResponse
+	if err := json.Unmarshal(w.Body.Bytes(), &resp); err != nil {
+		t.Fatal(err)
+	}
+	if w.Code != http.StatusBadRequest || len(resp.Errors) != 2 || resp.Errors[0].Field != "url" || resp.Errors[1].Field != "icon" {
+		t.Fatalf("status %d, body %s", w.Code, w.Body)
+	}
+	if bookmarks, _ := store.GetBookmarks(); len(bookmarks) != 0 {
+		t.Fatalf("校验失败的书签被保存: %+v", bookmarks)
+	}
+}
@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"sort"
+	"time"
+
+	"navdesk/auth"
+	"navdesk/middleware"
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// minPasswordLength 密码最小长度
+const minPasswordLength = 6
+
+// UsersHandler 用户管理处理器
+type UsersHandler struct {
+	storage  storage.Store
+	tokens   *storage.Tokens
+	sessions *storage.Sessions
+}
+
+// NewUsersHandler 创建用户管理处理器
+func NewUsersHandler(storage storage.Store, tokens *storage.Tokens, sessions *storage.Sessions) *UsersHandler {
+	return &UsersHandler{
+		storage:  storage,
+		tokens:   tokens,
+		sessions: sessions,
+	}
+}
+
+// GetUsers 获取用户列表
+func (h *UsersHandler) GetUsers(c *gin.Context) {
+	users, err := h.storage.GetUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "获取用户失败",
+		})
+		return
+	}
+
+	list := make([]models.UserInfo, 0, len(users))
+	for _, user := range users {
+		list = append(list, userInfo(user))
+	}
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Username < list[j].Username
+	})
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Data:    list,
+	})
+}
+
+// CreateUser 创建用户
+func (h *UsersHandler) CreateUser(c *gin.Context) {
+	var req models.CreateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "用户名、密码和角色不能为空",
+		})
+		return
+	}
+
+	if !auth.ValidUsername(req.Username) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "用户名只能包含字母、数字、下划线、点和横线，长度 3-32 个字符",
+		})
+		return
+	}
+	if !validRole(req.Role) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "无效的角色",
+		})
+		return
+	}
+	if len(req.Password) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "密码长度不能少于 6 个字符",
+		})
+		return
+	}
+
+	users, err := h.storage.GetUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "获取用户失败",
+		})
+		return
+	}
+
+	if _, exists := users[req.Username]; exists {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "用户名已存在",
+		})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "保存用户失败",
+		})
+		return
+	}
+
+	user := models.User{
+		Username:  req.Username,
+		Password:  hash,
+		Role:      req.Role,
+		CreatedAt: time.Now(),
+	}
+	users[user.Username] = user
+
+	if err := h.storage.SaveUsers(users); err != nil {
+		log.Printf("用户创建失败: %s - %v", user.Username, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "保存用户失败",
+		})
+		return
+	}
+
+	log.Printf("用户创建成功: %s (%s) - 操作者: %s", user.Username, user.Role, currentUsername(c))
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "用户创建成功",
+		Data:    userInfo(user),
+	})
+}
+
+// UpdateUser 更新用户角色、启用状态或重置密码
+func (h *UsersHandler) UpdateUser(c *gin.Context) {
+	username := c.Param("username")
+	var req models.UpdateUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "参数格式错误",
+		})
+		return
+	}
+
+	if req.Role != nil && !validRole(*req.Role) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "无效的角色",
+		})
+		return
+	}
+	if req.Password != "" && len(req.Password) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "密码长度不能少于 6 个字符",
+		})
+		return
+	}
+
+	users, err := h.storage.GetUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "获取用户失败",
+		})
+		return
+	}
+
+	user, exists := users[username]
+	if !exists {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "用户不存在",
+		})
+		return
+	}
+
+	updated := user
+	if req.Role != nil {
+		updated.Role = *req.Role
+	}
+	if req.Disabled != nil {
+		updated.Disabled = *req.Disabled
+	}
+
+	// 不能降级或禁用最后一个管理员
+	if isActiveAdmin(user) && !isActiveAdmin(updated) && countActiveAdmins(users) <= 1 {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "至少需要保留一个启用的管理员",
+		})
+		return
+	}
+
+	if updated.Disabled && username == currentUsername(c) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "不能禁用当前登录的账号",
+		})
+		return
+	}
+
+	if req.Password != "" {
+		hash, err := auth.HashPassword(req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "保存用户失败",
+			})
+			return
+		}
+		updated.Password = hash
+	}
+
+	if req.ResetTwoFactor {
+		updated.TOTPSecret = ""
+		updated.TOTPEnabled = false
+		updated.TOTPLastStep = 0
+		updated.RecoveryCodes = nil
+	}
+
+	updated.UpdatedAt = time.Now()
+	users[username] = updated
+
+	if err := h.storage.SaveUsers(users); err != nil {
+		log.Printf("用户更新失败: %s - %v", username, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "保存用户失败",
+		})
+		return
+	}
+
+	log.Printf("用户更新成功: %s (角色: %s, 禁用: %v, 重置密码: %v, 重置两步验证: %v) - 操作者: %s",
+		username, updated.Role, updated.Disabled, req.Password != "", req.ResetTwoFactor, currentUsername(c))
+
+	// 重置密码或禁用账号后，该用户已登录的会话全部失效
+	if req.Password != "" || updated.Disabled {
+		h.revokeSessions(username, "")
+	}
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "用户更新成功",
+		Data:    userInfo(updated),
+	})
+}
+
+// DeleteUser 删除用户
+func (h *UsersHandler) DeleteUser(c *gin.Context) {
+	username := c.Param("username")
+
+	if username == currentUsername(c) {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "不能删除当前登录的账号",
+		})
+		return
+	}
+
+	users, err := h.storage.GetUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "获取用户失败",
+		})
+		return
+	}
+
+	user, exists := users[username]
+	if !exists {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "用户不存在",
+		})
+		return
+	}
+
+	if isActiveAdmin(user) && countActiveAdmins(users) <= 1 {
+		c.JSON(http.StatusConflict, models.APIResponse{
+			Success: false,
+			Message: "至少需要保留一个启用的管理员",
+		})
+		return
+	}
+
+	delete(users, username)
+	if err := h.storage.SaveUsers(users); err != nil {
+		log.Printf("用户删除失败: %s - %v", username, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "删除用户失败",
+		})
+		return
+	}
+
+	log.Printf("用户删除成功: %s - 操作者: %s", username, currentUsername(c))
+
+	if revoked, err := h.tokens.RevokeUser(username); err != nil {
+		log.Printf("吊销用户 %s 的令牌失败: %v", username, err)
+	} else if revoked > 0 {
+		log.Printf("已吊销用户 %s 的 %d 个令牌", username, revoked)
+	}
+	h.revokeSessions(username, "")
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "用户删除成功",
+	})
+}
+
+// ChangePassword 修改当前登录用户的密码
+func (h *UsersHandler) ChangePassword(c *gin.Context) {
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "原密码和新密码不能为空",
+		})
+		return
+	}
+
+	if len(req.NewPassword) < minPasswordLength {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "密码长度不能少于 6 个字符",
+		})
+		return
+	}
+
+	users, err := h.storage.GetUsers()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "获取用户失败",
+		})
+		return
+	}
+
+	username := currentUsername(c)
+	user, exists := users[username]
+	if !exists {
+		c.JSON(http.StatusNotFound, models.APIResponse{
+			Success: false,
+			Message: "用户不存在",
+		})
+		return
+	}
+
+	if user.Provider != "" {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "该账号由外部身份源管理，请在对应系统中修改密码",
+		})
+		return
+	}
+
+	if ok, _ := auth.CheckPassword(user.Password, req.OldPassword); !ok {
+		c.JSON(http.StatusBadRequest, models.APIResponse{
+			Success: false,
+			Message: "原密码错误",
+		})
+		return
+	}
+
+	hash, err := auth.HashPassword(req.NewPassword)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "修改密码失败",
+		})
+		return
+	}
+
+	user.Password = hash
+	user.UpdatedAt = time.Now()
+	users[username] = user
+
+	if err := h.storage.SaveUsers(users); err != nil {
+		log.Printf("修改密码失败: %s - %v", username, err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "修改密码失败",
+		})
+		return
+	}
+
+	log.Printf("用户修改密码成功: %s", username)
+
+	// 修改密码后注销其他设备上的会话，当前会话保留
+	h.revokeSessions(username, middleware.SessionID(c))
+
+	c.JSON(http.StatusOK, models.APIResponse{
+		Success: true,
+		Message: "密码修改成功",
+	})
+}
+
+// revokeSessions 注销用户除 exceptID 以外的会话，失败时只记录日志
+func (h *UsersHandler) revokeSessions(username, exceptID string) {
+	if revoked, err := h.sessions.RevokeUser(username, exceptID); err != nil {
+		log.Printf("注销用户 %s 的会话失败: %v", username, err)
+	} else if revoked > 0 {
+		log.Printf("已注销用户 %s 的 %d 个会话", username, revoked)
+	}
+}
+
+// userInfo 转换为不含密码的用户信息
+func userInfo(user models.User) models.UserInfo {
+	return models.UserInfo{
+		Username:    user.Username,
+		Role:        user.Role,
+		Disabled:    user.Disabled,
+		TOTPEnabled: user.TOTPEnabled,
+		Provider:    user.Provider,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+	}
+}
+
+// validRole 判断角色是否有效
+func validRole(role string) bool {
+	switch role {
+	case models.RoleAdmin, models.RoleEditor, models.RoleViewer:
+		return true
+	}
+	return false
+}
+
+func isActiveAdmin(user models.User) bool {
+	return user.Role == models.RoleAdmin && !user.Disabled
+}
+
+// countActiveAdmins 统计启用状态的管理员数量
+func countActiveAdmins(users map[string]models.User) int {
+	count := 0
+	for _, user := range users {
+		if isActiveAdmin(user) {
+			count++
+		}
+	}
+	return count
+}
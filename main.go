@@ -1,40 +1,222 @@
 package main
 
 import (
+	"crypto/tls"
+	"crypto/x509"
 	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-contrib/cors"
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-contrib/sessions/cookie"
 	"github.com/gin-gonic/gin"
 
+	"navdesk/auth"
 	"navdesk/handlers"
 	"navdesk/middleware"
 	"navdesk/models"
 	"navdesk/storage"
 )
 
+// sessionMaxAge 登录会话的最长有效期
+const sessionMaxAge = 30 * 24 * time.Hour
+
 func main() {
+	// 子命令：navdesk check [--repair]
+	if len(os.Args) > 1 && os.Args[1] == "check" {
+		os.Exit(runCheck(os.Args[2:]))
+	}
+
 	// 获取端口号
 	port := os.Getenv("PORT")
 	if port == "" {
 		port = "3000"
 	}
 
-	// 创建存储实例
-	store := storage.NewStorage()
+	// 创建存储实例（STORAGE_DRIVER: json 或 sqlite，默认 json）
+	store, err := storage.New(os.Getenv("STORAGE_DRIVER"), os.Getenv("DATA_DIR"))
+	if err != nil {
+		log.Fatalf("初始化存储失败: %v", err)
+	}
+	defer store.Close()
+
+	// 将 users.json 中遗留的明文密码升级为哈希
+	if err := auth.UpgradePasswords(store); err != nil {
+		log.Fatalf("升级用户密码失败: %v", err)
+	}
+
+	// 数据快照（SNAPSHOT_INTERVAL 为定时间隔，0 表示关闭；SNAPSHOT_KEEP 为每种原因的保留数量）
+	snapshots := storage.NewSnapshotManager(store, envInt("SNAPSHOT_KEEP", 20))
+	if interval := envDuration("SNAPSHOT_INTERVAL", 24*time.Hour); interval > 0 {
+		snapshots.Schedule(interval)
+		defer snapshots.Stop()
+	}
 
 	// 初始化操作已移除，项目使用预打包的数据文件
 
+	// 回收站（TRASH_RETENTION_DAYS 天后自动永久删除，0 表示不自动清理）
+	trash := storage.NewTrash(store, envInt("TRASH_RETENTION_DAYS", 30))
+	trash.Schedule(time.Hour)
+	defer trash.Stop()
+
+	// 修订历史（HISTORY_LIMIT 为每条记录保留的修订数量，0 表示不限制）
+	history := storage.NewHistory(store, envInt("HISTORY_LIMIT", 50))
+
+	// 个人 API 令牌
+	tokens := storage.NewTokens(store)
+
+	// 服务端登录会话（SESSION_IDLE_TIMEOUT 为空闲超时，0 表示不限制），有效期与 Cookie 相同
+	loginSessions := storage.NewSessions(store, envDuration("SESSION_IDLE_TIMEOUT", 7*24*time.Hour), sessionMaxAge)
+
 	// 创建Gin路由器
 	r := gin.Default()
 
-	// CORS设置
-	r.Use(cors.Default())
+	// 可信反向代理（TRUSTED_PROXIES），只有来自这些地址的 X-Forwarded-For 才用于识别客户端 IP
+	if err := r.SetTrustedProxies(envList("TRUSTED_PROXIES")); err != nil {
+		log.Fatalf("TRUSTED_PROXIES 配置错误: %v", err)
+	}
+
+	// 管理功能访问网段（ADMIN_ALLOW_CIDRS），为空表示不限制
+	adminNetworks, err := middleware.ParseCIDRs(os.Getenv("ADMIN_ALLOW_CIDRS"))
+	if err != nil {
+		log.Fatalf("ADMIN_ALLOW_CIDRS 配置错误: %v", err)
+	}
+	if len(adminNetworks) > 0 {
+		log.Printf("管理功能仅允许以下网段访问: %s", os.Getenv("ADMIN_ALLOW_CIDRS"))
+	}
+	adminAccess := middleware.AllowNetworks(adminNetworks)
+
+	// 登录限流（LOGIN_MAX_ATTEMPTS 次失败后锁定 LOGIN_LOCKOUT 时长，0 表示不限制）
+	loginThrottle := auth.NewLoginThrottle(envInt("LOGIN_MAX_ATTEMPTS", 5), envDuration("LOGIN_LOCKOUT", 15*time.Minute))
+
+	// LDAP / Active Directory 登录（设置 LDAP_URL 后启用），users.json 中的本地账号仍可登录
+	var ldapOptions *handlers.LDAPOptions
+	if ldapURL := os.Getenv("LDAP_URL"); ldapURL != "" {
+		tlsConfig := &tls.Config{InsecureSkipVerify: os.Getenv("LDAP_INSECURE_SKIP_VERIFY") == "true"}
+		if caFile := os.Getenv("LDAP_CA_FILE"); caFile != "" {
+			pem, err := os.ReadFile(caFile)
+			if err != nil {
+				log.Fatalf("读取 LDAP_CA_FILE 失败: %v", err)
+			}
+			tlsConfig.RootCAs = x509.NewCertPool()
+			if !tlsConfig.RootCAs.AppendCertsFromPEM(pem) {
+				log.Fatalf("LDAP_CA_FILE 中没有有效的证书: %s", caFile)
+			}
+		}
+		startTLS := os.Getenv("LDAP_STARTTLS") != "false"
+		if strings.HasPrefix(ldapURL, "ldap://") && !startTLS {
+			log.Printf("安全警告: LDAP 连接未加密，密码将以明文传输，仅应在本机测试时使用")
+		}
+
+		authenticator, err := auth.NewLDAPAuthenticator(auth.LDAPConfig{
+			URL:               ldapURL,
+			StartTLS:          startTLS,
+			TLS:               tlsConfig,
+			BindDN:            os.Getenv("LDAP_BIND_DN"),
+			BindPassword:      os.Getenv("LDAP_BIND_PASSWORD"),
+			BaseDN:            os.Getenv("LDAP_BASE_DN"),
+			UserFilter:        envString("LDAP_USER_FILTER", "(uid=%s)"),
+			UsernameAttribute: envString("LDAP_USERNAME_ATTRIBUTE", "uid"),
+			GroupAttribute:    envString("LDAP_GROUP_ATTRIBUTE", "memberOf"),
+		})
+		if err != nil {
+			log.Fatalf("LDAP 配置错误: %v", err)
+		}
+		roleMap, err := auth.ParseRoleMap(os.Getenv("LDAP_ROLE_MAP"))
+		if err != nil {
+			log.Fatalf("LDAP_ROLE_MAP 配置错误: %v", err)
+		}
+		defaultRole := os.Getenv("LDAP_DEFAULT_ROLE")
+		if defaultRole != "" && auth.RoleLevel(defaultRole) == 0 {
+			log.Fatalf("LDAP_DEFAULT_ROLE 配置错误: 无效的角色 %s", defaultRole)
+		}
+
+		ldapOptions = &handlers.LDAPOptions{
+			Authenticator: authenticator,
+			RoleMap:       roleMap,
+			DefaultRole:   defaultRole,
+		}
+		log.Printf("已启用 LDAP 登录: %s", ldapURL)
+	}
+
+	// OpenID Connect 单点登录（设置 OIDC_ISSUER 后启用）
+	var oidcProvider *auth.OIDCProvider
+	var oidcOptions handlers.OIDCOptions
+	if issuer := os.Getenv("OIDC_ISSUER"); issuer != "" {
+		if os.Getenv("OIDC_CLIENT_ID") == "" {
+			log.Fatal("已设置 OIDC_ISSUER，但缺少 OIDC_CLIENT_ID")
+		}
+		roleMap, err := auth.ParseRoleMap(os.Getenv("OIDC_ROLE_MAP"))
+		if err != nil {
+			log.Fatalf("OIDC_ROLE_MAP 配置错误: %v", err)
+		}
+		defaultRole := os.Getenv("OIDC_DEFAULT_ROLE")
+		if defaultRole != "" && auth.RoleLevel(defaultRole) == 0 {
+			log.Fatalf("OIDC_DEFAULT_ROLE 配置错误: 无效的角色 %s", defaultRole)
+		}
+
+		oidcProvider = auth.NewOIDCProvider(auth.OIDCConfig{
+			Issuer:       issuer,
+			ClientID:     os.Getenv("OIDC_CLIENT_ID"),
+			ClientSecret: os.Getenv("OIDC_CLIENT_SECRET"),
+			Scopes:       strings.Fields(envString("OIDC_SCOPES", "openid profile email")),
+		})
+		oidcOptions = handlers.OIDCOptions{
+			RedirectURL:   os.Getenv("OIDC_REDIRECT_URL"),
+			UsernameClaim: envString("OIDC_USERNAME_CLAIM", "preferred_username"),
+			RoleClaim:     envString("OIDC_ROLE_CLAIM", "groups"),
+			RoleMap:       roleMap,
+			DefaultRole:   defaultRole,
+			AutoCreate:    os.Getenv("OIDC_AUTO_CREATE") == "true",
+			LinkExisting:  os.Getenv("OIDC_LINK_EXISTING") == "true",
+		}
+		log.Printf("已启用单点登录: %s", issuer)
+	}
+
+	// 安全响应头（CONTENT_SECURITY_POLICY、FRAME_OPTIONS 等），设置为 off 表示不发送；
+	// CSP_REPORT_ONLY=true 时 CSP 只报告违规、不拦截，用于调整策略
+	securityHeaders, err := middleware.SecurityHeaders(middleware.SecurityHeadersConfig{
+		ContentSecurityPolicy: envHeader("CONTENT_SECURITY_POLICY", middleware.DefaultContentSecurityPolicy),
+		CSPReportOnly:         os.Getenv("CSP_REPORT_ONLY") == "true",
+		CSPReportURI:          os.Getenv("CSP_REPORT_URI"),
+		FrameOptions:          envHeader("FRAME_OPTIONS", "SAMEORIGIN"),
+		ReferrerPolicy:        envHeader("REFERRER_POLICY", middleware.DefaultReferrerPolicy),
+		PermissionsPolicy:     envHeader("PERMISSIONS_POLICY", middleware.DefaultPermissionsPolicy),
+		HSTSMaxAge:            envDuration("HSTS_MAX_AGE", 365*24*time.Hour),
+		HSTSIncludeSubdomains: os.Getenv("HSTS_INCLUDE_SUBDOMAINS") == "true",
+	})
+	if err != nil {
+		log.Fatalf("FRAME_OPTIONS 配置错误: %v", err)
+	}
+	r.Use(securityHeaders)
+	if os.Getenv("CSP_REPORT_ONLY") == "true" {
+		log.Printf("内容安全策略为仅报告模式，违规不会被拦截")
+	}
+
+	// CORS设置（CORS_ALLOW_ORIGINS），为空时只允许同源访问；跨域请求需使用 API 令牌，不携带 Cookie
+	if origins := envList("CORS_ALLOW_ORIGINS"); len(origins) > 0 {
+		corsConfig := cors.Config{
+			AllowMethods:  []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+			AllowHeaders:  []string{"Origin", "Content-Type", "Authorization", "If-Match"},
+			ExposeHeaders: []string{"ETag"},
+			MaxAge:        12 * time.Hour,
+		}
+		if len(origins) == 1 && origins[0] == "*" {
+			corsConfig.AllowAllOrigins = true
+		} else {
+			corsConfig.AllowOrigins = origins
+		}
+		if err := corsConfig.Validate(); err != nil {
+			log.Fatalf("CORS_ALLOW_ORIGINS 配置错误: %v", err)
+		}
+		r.Use(cors.New(corsConfig))
+	}
 
 	// Session设置
 	secretKey := os.Getenv("SESSION_SECRET")
@@ -55,20 +237,48 @@ func main() {
 	cookieStore.Options(sessions.Options{
 		Path:     "/",
 		Domain:   "",
-		MaxAge:   30 * 24 * 60 * 60, // 30天持久化 (30天 * 24小时 * 60分钟 * 60秒)
+		MaxAge:   int(sessionMaxAge.Seconds()), // 30天持久化
 		HttpOnly: true,
 		Secure:   false, // 在生产环境中应设为true
 		SameSite: http.SameSiteDefaultMode,
 	})
 	r.Use(sessions.Sessions("navdesk_session", cookieStore))
+	r.Use(middleware.CSRF(store, tokens))
+	r.Use(middleware.ServerSessions(loginSessions))
 
-	// 静态文件服务
-	r.Static("/static", "./public")
-	r.StaticFS("/uploads", http.Dir("./data/uploads"))
+	// 反向代理认证（设置 PROXY_AUTH_CIDRS 后启用），信任认证代理转发的 Remote-User / Remote-Groups 请求头
+	if cidrs := os.Getenv("PROXY_AUTH_CIDRS"); cidrs != "" {
+		networks, err := middleware.ParseCIDRs(cidrs)
+		if err != nil {
+			log.Fatalf("PROXY_AUTH_CIDRS 配置错误: %v", err)
+		}
+		roleMap, err := auth.ParseRoleMap(os.Getenv("PROXY_AUTH_ROLE_MAP"))
+		if err != nil {
+			log.Fatalf("PROXY_AUTH_ROLE_MAP 配置错误: %v", err)
+		}
+		defaultRole := os.Getenv("PROXY_AUTH_DEFAULT_ROLE")
+		if defaultRole != "" && auth.RoleLevel(defaultRole) == 0 {
+			log.Fatalf("PROXY_AUTH_DEFAULT_ROLE 配置错误: 无效的角色 %s", defaultRole)
+		}
+		r.Use(middleware.ProxyAuth(store, loginSessions, middleware.ProxyAuthConfig{
+			Networks:     networks,
+			UserHeader:   envString("PROXY_AUTH_USER_HEADER", "Remote-User"),
+			GroupsHeader: envString("PROXY_AUTH_GROUPS_HEADER", "Remote-Groups"),
+			RoleMap:      roleMap,
+			DefaultRole:  defaultRole,
+		}))
+		log.Printf("已启用反向代理认证，可信代理: %s", cidrs)
+	}
+
+	// 静态文件服务。只开放样式与脚本目录，后台页面由下方带 adminAccess 的路由提供，
+	// 不能经 /static/admin/ 绕过管理网段限制
+	r.Static("/static/css", "./public/css")
+	r.Static("/static/js", "./public/js")
+	r.Group("/uploads", middleware.UploadHeaders()).StaticFS("/", http.Dir(store.GetUploadsPath()))
 
 	// Favicon服务
-	r.GET("/favicon.ico", func(c *gin.Context) {
-		faviconPath := filepath.Join("./data/uploads/favicon/favicon.ico")
+	r.GET("/favicon.ico", middleware.UploadHeaders(), func(c *gin.Context) {
+		faviconPath := filepath.Join(store.GetUploadsPath(), "favicon", "favicon.ico")
 		if _, err := os.Stat(faviconPath); err == nil {
 			c.File(faviconPath)
 		} else {
@@ -76,12 +286,37 @@ func main() {
 		}
 	})
 
+	// 书签网址允许的协议（BOOKMARK_URL_SCHEMES），默认只允许 http 和 https
+	urlPolicy, err := handlers.NewURLPolicy(envList("BOOKMARK_URL_SCHEMES"))
+	if err != nil {
+		log.Fatalf("BOOKMARK_URL_SCHEMES 配置错误: %v", err)
+	}
+	log.Printf("书签网址允许的协议: %s", strings.Join(urlPolicy.Schemes(), ", "))
+
 	// 创建处理器
-	authHandler := handlers.NewAuthHandler(store)
-	categoriesHandler := handlers.NewCategoriesHandler(store)
-	bookmarksHandler := handlers.NewBookmarksHandler(store)
+	authHandler := handlers.NewAuthHandler(store, loginSessions, loginThrottle, ldapOptions, oidcProvider != nil)
+	categoriesHandler := handlers.NewCategoriesHandler(store, snapshots, trash, history)
+	bookmarksHandler := handlers.NewBookmarksHandler(store, trash, history, urlPolicy)
 	uploadHandler := handlers.NewUploadHandler(store)
-	settingsHandler := handlers.NewSettingsHandler(store)
+	settingsHandler := handlers.NewSettingsHandler(store, history)
+	snapshotsHandler := handlers.NewSnapshotsHandler(snapshots)
+	trashHandler := handlers.NewTrashHandler(trash)
+	historyHandler := handlers.NewHistoryHandler(store, history, urlPolicy)
+	integrityHandler := handlers.NewIntegrityHandler(store, snapshots)
+	usersHandler := handlers.NewUsersHandler(store, tokens, loginSessions)
+	tokensHandler := handlers.NewTokensHandler(tokens)
+	twoFactorHandler := handlers.NewTwoFactorHandler(store, envString("TOTP_ISSUER", "navdesk"))
+	sessionsHandler := handlers.NewSessionsHandler(loginSessions)
+
+	// 写操作锁，串行化所有修改数据的请求
+	writeLock := middleware.WriteLock(store)
+
+	// 登录认证，接受会话或 API 令牌
+	requireAuth := middleware.RequireAuth(store, tokens)
+
+	// 角色权限：管理员管理用户、设置与数据维护，编辑者管理书签与分类，查看者只读
+	requireAdmin := middleware.RequireRole(models.RoleAdmin)
+	requireEditor := middleware.RequireRole(models.RoleAdmin, models.RoleEditor)
 
 	// API路由组
 	api := r.Group("/api")
@@ -89,9 +324,56 @@ func main() {
 	// 认证相关路由
 	auth := api.Group("/auth")
 	{
-		auth.POST("/login", authHandler.Login)
+		auth.POST("/login", adminAccess, authHandler.Login)
+		auth.POST("/login/2fa", adminAccess, writeLock, authHandler.VerifyTwoFactor)
 		auth.POST("/logout", authHandler.Logout)
 		auth.GET("/status", authHandler.Status)
+		auth.POST("/password", adminAccess, requireAuth, writeLock, usersHandler.ChangePassword)
+	}
+
+	// 单点登录路由
+	if oidcProvider != nil {
+		oidcHandler := handlers.NewOIDCHandler(store, loginSessions, oidcProvider, oidcOptions)
+		oidcRoutes := api.Group("/auth/oidc", adminAccess)
+		{
+			oidcRoutes.GET("/login", oidcHandler.Login)
+			oidcRoutes.GET("/callback", oidcHandler.Callback)
+		}
+	}
+
+	// 两步验证路由（当前用户）
+	twoFactorRoutes := api.Group("/auth/2fa", adminAccess, requireAuth)
+	{
+		twoFactorRoutes.GET("/", twoFactorHandler.GetStatus)
+		twoFactorRoutes.POST("/setup", writeLock, twoFactorHandler.Setup)
+		twoFactorRoutes.POST("/enable", writeLock, twoFactorHandler.Enable)
+		twoFactorRoutes.POST("/disable", writeLock, twoFactorHandler.Disable)
+		twoFactorRoutes.POST("/recovery-codes", writeLock, twoFactorHandler.RegenerateRecoveryCodes)
+	}
+
+	// 登录会话路由（当前用户）
+	sessionRoutes := api.Group("/auth/sessions", adminAccess, requireAuth)
+	{
+		sessionRoutes.GET("/", sessionsHandler.GetSessions)
+		sessionRoutes.DELETE("/:id", sessionsHandler.RevokeSession)
+		sessionRoutes.DELETE("/", sessionsHandler.RevokeOtherSessions)
+	}
+
+	// API 令牌路由
+	tokenRoutes := api.Group("/tokens", adminAccess, requireAuth)
+	{
+		tokenRoutes.GET("/", tokensHandler.GetTokens)
+		tokenRoutes.POST("/", tokensHandler.CreateToken)
+		tokenRoutes.DELETE("/:id", tokensHandler.RevokeToken)
+	}
+
+	// 用户管理路由
+	userRoutes := api.Group("/users", adminAccess, requireAuth, requireAdmin)
+	{
+		userRoutes.GET("/", usersHandler.GetUsers)
+		userRoutes.POST("/", writeLock, usersHandler.CreateUser)
+		userRoutes.PUT("/:username", writeLock, usersHandler.UpdateUser)
+		userRoutes.DELETE("/:username", writeLock, usersHandler.DeleteUser)
 	}
 
 	// 分类相关路由
@@ -99,9 +381,12 @@ func main() {
 	{
 		categories.GET("/", categoriesHandler.GetCategories)
 		categories.GET("/:id", categoriesHandler.GetCategory)
-		categories.POST("/", middleware.RequireAuth(), categoriesHandler.CreateCategory)
-		categories.PUT("/:id", middleware.RequireAuth(), categoriesHandler.UpdateCategory)
-		categories.DELETE("/:id", middleware.RequireAuth(), categoriesHandler.DeleteCategory)
+		categories.POST("/", adminAccess, requireAuth, requireEditor, writeLock, categoriesHandler.CreateCategory)
+		categories.PUT("/:id", adminAccess, requireAuth, requireEditor, writeLock, categoriesHandler.UpdateCategory)
+		categories.DELETE("/:id", adminAccess, requireAuth, requireEditor, writeLock, categoriesHandler.DeleteCategory)
+		categories.GET("/:id/history", adminAccess, requireAuth, historyHandler.GetHistory(storage.HistoryCategory))
+		categories.GET("/:id/history/:rev", adminAccess, requireAuth, historyHandler.GetRevision(storage.HistoryCategory))
+		categories.POST("/:id/history/:rev/revert", adminAccess, requireAuth, requireEditor, writeLock, historyHandler.RevertRevision(storage.HistoryCategory))
 	}
 
 	// 书签相关路由
@@ -111,23 +396,54 @@ func main() {
 		bookmarks.GET("/category/:categoryId", bookmarksHandler.GetBookmarksByCategory)
 		bookmarks.GET("/search/:keyword", bookmarksHandler.SearchBookmarksH)
 		bookmarks.GET("/:id", bookmarksHandler.GetBookmark)
-		bookmarks.POST("/", middleware.RequireAuth(), bookmarksHandler.CreateBookmark)
-		bookmarks.PUT("/:id", middleware.RequireAuth(), bookmarksHandler.UpdateBookmark)
-		bookmarks.DELETE("/:id", middleware.RequireAuth(), bookmarksHandler.DeleteBookmark)
+		bookmarks.POST("/", adminAccess, requireAuth, requireEditor, writeLock, bookmarksHandler.CreateBookmark)
+		bookmarks.PUT("/:id", adminAccess, requireAuth, requireEditor, writeLock, bookmarksHandler.UpdateBookmark)
+		bookmarks.DELETE("/:id", adminAccess, requireAuth, requireEditor, writeLock, bookmarksHandler.DeleteBookmark)
+		bookmarks.GET("/:id/history", adminAccess, requireAuth, historyHandler.GetHistory(storage.HistoryBookmark))
+		bookmarks.GET("/:id/history/:rev", adminAccess, requireAuth, historyHandler.GetRevision(storage.HistoryBookmark))
+		bookmarks.POST("/:id/history/:rev/revert", adminAccess, requireAuth, requireEditor, writeLock, historyHandler.RevertRevision(storage.HistoryBookmark))
 	}
 
 	// 上传相关路由
-	upload := api.Group("/upload", middleware.RequireAuth())
+	upload := api.Group("/upload", adminAccess, requireAuth)
 	{
-		upload.POST("/icon", uploadHandler.UploadIcon)
-		upload.POST("/favicon", uploadHandler.UploadFavicon)
+		upload.POST("/icon", requireEditor, writeLock, uploadHandler.UploadIcon)
+		upload.POST("/favicon", requireAdmin, writeLock, uploadHandler.UploadFavicon)
 	}
 
 	// 设置相关路由
 	settings := api.Group("/settings")
 	{
 		settings.GET("/", settingsHandler.GetSettings)
-		settings.POST("/", middleware.RequireAuth(), settingsHandler.UpdateSettings)
+		settings.POST("/", adminAccess, requireAuth, requireAdmin, writeLock, settingsHandler.UpdateSettings)
+		settings.GET("/history", adminAccess, requireAuth, historyHandler.GetHistory(storage.HistorySettings))
+		settings.GET("/history/:rev", adminAccess, requireAuth, historyHandler.GetRevision(storage.HistorySettings))
+		settings.POST("/history/:rev/revert", adminAccess, requireAuth, requireAdmin, writeLock, historyHandler.RevertRevision(storage.HistorySettings))
+	}
+
+	// 快照相关路由
+	snapshotRoutes := api.Group("/snapshots", adminAccess, requireAuth, requireAdmin)
+	{
+		snapshotRoutes.GET("/", snapshotsHandler.GetSnapshots)
+		snapshotRoutes.POST("/", writeLock, snapshotsHandler.CreateSnapshot)
+		snapshotRoutes.GET("/:id/download", snapshotsHandler.DownloadSnapshot)
+		snapshotRoutes.POST("/:id/restore", writeLock, snapshotsHandler.RestoreSnapshot)
+	}
+
+	// 回收站相关路由
+	trashRoutes := api.Group("/trash", adminAccess, requireAuth)
+	{
+		trashRoutes.GET("/", trashHandler.GetTrash)
+		trashRoutes.POST("/:id/restore", requireEditor, writeLock, trashHandler.RestoreTrashItem)
+		trashRoutes.DELETE("/:id", requireEditor, writeLock, trashHandler.PurgeTrashItem)
+		trashRoutes.DELETE("/", requireEditor, writeLock, trashHandler.EmptyTrash)
+	}
+
+	// 数据完整性检查路由
+	integrityRoutes := api.Group("/integrity", adminAccess, requireAuth, requireAdmin)
+	{
+		integrityRoutes.GET("/", integrityHandler.CheckIntegrity)
+		integrityRoutes.POST("/repair", writeLock, integrityHandler.RepairIntegrity)
 	}
 
 	// 前端数据接口
@@ -190,12 +506,12 @@ func main() {
 	})
 
 	// 后台登录页面（不需要认证）
-	r.GET("/admin/login.html", func(c *gin.Context) {
+	r.GET("/admin/login.html", adminAccess, func(c *gin.Context) {
 		c.File("./public/admin/login.html")
 	})
 
 	// 需要认证的后台页面
-	r.GET("/admin/categories.html", func(c *gin.Context) {
+	r.GET("/admin/categories.html", adminAccess, func(c *gin.Context) {
 		session := sessions.Default(c)
 		username := session.Get("username")
 		log.Printf("访问 /admin/categories.html - Session中的username: %v", username)
@@ -208,7 +524,7 @@ func main() {
 		c.File("./public/admin/categories.html")
 	})
 
-	r.GET("/admin/category-detail.html", func(c *gin.Context) {
+	r.GET("/admin/category-detail.html", adminAccess, func(c *gin.Context) {
 		session := sessions.Default(c)
 		username := session.Get("username")
 		if username == nil {
@@ -218,22 +534,36 @@ func main() {
 		c.File("./public/admin/category-detail.html")
 	})
 
-	r.GET("/admin/settings.html", func(c *gin.Context) {
+	r.GET("/admin/settings.html", adminAccess, func(c *gin.Context) {
 		session := sessions.Default(c)
 		username := session.Get("username")
 		if username == nil {
 			c.Redirect(http.StatusFound, "/admin/login.html")
 			return
 		}
+		if session.Get("role") != models.RoleAdmin {
+			c.Redirect(http.StatusFound, "/admin/categories.html")
+			return
+		}
 		c.File("./public/admin/settings.html")
 	})
 
+	r.GET("/admin/users.html", adminAccess, func(c *gin.Context) {
+		session := sessions.Default(c)
+		username := session.Get("username")
+		if username == nil {
+			c.Redirect(http.StatusFound, "/admin/login.html")
+			return
+		}
+		c.File("./public/admin/users.html")
+	})
+
 	// 默认路由
 	r.GET("/", func(c *gin.Context) {
 		c.File("./public/index.html")
 	})
 
-	r.GET("/admin", func(c *gin.Context) {
+	r.GET("/admin", adminAccess, func(c *gin.Context) {
 		session := sessions.Default(c)
 		username := session.Get("username")
 		if username != nil {
@@ -243,7 +573,7 @@ func main() {
 		}
 	})
 
-	r.GET("/admin/", func(c *gin.Context) {
+	r.GET("/admin/", adminAccess, func(c *gin.Context) {
 		session := sessions.Default(c)
 		username := session.Get("username")
 		if username != nil {
@@ -259,12 +589,7 @@ func main() {
 	log.Printf("后台管理: http://localhost:%s/admin", port)
 
 	// 检查是否使用默认密码
-	users, err := store.GetUsers()
-	if err == nil {
-		if adminUser, exists := users["admin"]; exists && adminUser.Password == "123456" {
-			log.Printf("默认账号: admin / 123456，请在 data/users.json 中修改密码！")
-		}
-	}
+	warnDefaultPassword(store)
 
 	// 安全提示
 	if secretKey == "your-secure-random-key-2025-navdesk-session" {
@@ -275,3 +600,78 @@ func main() {
 		log.Fatalf("Failed to start server: %v", err)
 	}
 }
+
+// warnDefaultPassword admin 仍使用默认密码时输出提醒。密码以哈希保存，需要通过校验判断
+func warnDefaultPassword(store storage.Store) {
+	users, err := store.GetUsers()
+	if err != nil {
+		return
+	}
+	if adminUser, exists := users["admin"]; exists {
+		if isDefault, _ := auth.CheckPassword(adminUser.Password, "123456"); isDefault {
+			log.Printf("默认账号: admin / 123456，请登录后台修改密码！")
+		}
+	}
+}
+
+// envString 读取字符串环境变量，未设置时使用默认值
+func envString(key, fallback string) string {
+	if value := os.Getenv(key); value != "" {
+		return value
+	}
+	return fallback
+}
+
+// envInt 读取整数环境变量，未设置或格式错误时返回默认值
+func envInt(key string, fallback int) int {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		log.Printf("环境变量 %s 格式错误，使用默认值 %d", key, fallback)
+		return fallback
+	}
+	return n
+}
+
+// envDuration 读取时间间隔环境变量（如 24h、30m，0 表示关闭）
+func envDuration(key string, fallback time.Duration) time.Duration {
+	value := os.Getenv(key)
+	if value == "" {
+		return fallback
+	}
+	if value == "0" {
+		return 0
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		log.Printf("环境变量 %s 格式错误，使用默认值 %s", key, fallback)
+		return fallback
+	}
+	return d
+}
+
+// envHeader 读取响应头取值环境变量，未设置时使用默认值，off 表示不发送该响应头
+func envHeader(key, fallback string) string {
+	value := strings.TrimSpace(os.Getenv(key))
+	switch {
+	case value == "":
+		return fallback
+	case strings.EqualFold(value, "off"):
+		return ""
+	}
+	return value
+}
+
+// envList 读取逗号分隔的列表环境变量，忽略空项
+func envList(key string) []string {
+	var items []string
+	for _, item := range strings.Split(os.Getenv(key), ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -1,18 +1,40 @@
 package middleware
 
 import (
+	"errors"
+	"log"
 	"net/http"
+	"strings"
 	"time"
 
+	"navdesk/auth"
 	"navdesk/models"
+	"navdesk/storage"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
 )
 
-// RequireAuth 需要登录认证的中间件
-func RequireAuth() gin.HandlerFunc {
+// 请求上下文中的键
+const (
+	currentUserKey = "currentUser"
+	apiTokenKey    = "apiToken"
+)
+
+// RequireAuth 需要登录认证的中间件，接受会话 Cookie 或 Authorization: Bearer 令牌。
+// 每次请求都会核对用户是否仍然存在且未被禁用，删除或禁用用户后其会话与令牌立即失效
+func RequireAuth(store storage.Store, tokens *storage.Tokens) gin.HandlerFunc {
 	return func(c *gin.Context) {
+		// 修改类请求的令牌已由 CSRF 中间件校验
+		if APIToken(c) != nil {
+			c.Next()
+			return
+		}
+		if raw, ok := bearerToken(c); ok {
+			authenticateToken(c, store, tokens, raw)
+			return
+		}
+
 		session := sessions.Default(c)
 		username := session.Get("username")
 
@@ -25,10 +47,172 @@ func RequireAuth() gin.HandlerFunc {
 			return
 		}
 
+		user, err := findUser(store, username.(string))
+		if err != nil {
+			log.Printf("读取用户失败: %v", err)
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "读取用户失败",
+			})
+			c.Abort()
+			return
+		}
+		if user == nil || user.Disabled {
+			clearLogin(session)
+			session.Save()
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Message: "账号不存在或已被禁用，请重新登录",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Set(currentUserKey, user)
 		c.Next()
 	}
 }
 
+// RequireRole 限制只有指定角色的用户可以访问，需在 RequireAuth 之后使用。
+// 角色取自 RequireAuth 读取的最新用户数据，修改角色后立即生效。
+// 使用令牌访问时，令牌的权限范围还需达到 roles 中最低角色对应的等级
+func RequireRole(roles ...string) gin.HandlerFunc {
+	required := 0
+	for _, role := range roles {
+		if level := auth.RoleLevel(role); required == 0 || level < required {
+			required = level
+		}
+	}
+
+	return func(c *gin.Context) {
+		user := CurrentUser(c)
+		if user == nil {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Message: "需要登录",
+			})
+			c.Abort()
+			return
+		}
+
+		allowed := false
+		for _, role := range roles {
+			if user.Role == role {
+				allowed = true
+				break
+			}
+		}
+
+		if allowed {
+			if token := APIToken(c); token != nil && auth.ScopesLevel(token.Scopes) < required {
+				log.Printf("令牌权限不足: 用户 %s 的令牌 %s 访问 %s %s", user.Username, token.Name, c.Request.Method, c.Request.URL.Path)
+				c.JSON(http.StatusForbidden, models.APIResponse{
+					Success: false,
+					Message: "令牌权限不足",
+				})
+				c.Abort()
+				return
+			}
+			c.Next()
+			return
+		}
+
+		log.Printf("权限不足: 用户 %s (%s) 访问 %s %s", user.Username, user.Role, c.Request.Method, c.Request.URL.Path)
+		c.JSON(http.StatusForbidden, models.APIResponse{
+			Success: false,
+			Message: "权限不足",
+		})
+		c.Abort()
+	}
+}
+
+// CurrentUser 返回 RequireAuth 校验通过的用户，未经过 RequireAuth 时返回 nil
+func CurrentUser(c *gin.Context) *models.User {
+	if value, exists := c.Get(currentUserKey); exists {
+		if user, ok := value.(*models.User); ok {
+			return user
+		}
+	}
+	return nil
+}
+
+// APIToken 返回本次请求使用的 API 令牌，使用会话登录时返回 nil
+func APIToken(c *gin.Context) *models.APIToken {
+	if value, exists := c.Get(apiTokenKey); exists {
+		if token, ok := value.(*models.APIToken); ok {
+			return token
+		}
+	}
+	return nil
+}
+
+// bearerToken 读取 Authorization: Bearer 请求头
+func bearerToken(c *gin.Context) (string, bool) {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return "", false
+	}
+	return strings.TrimSpace(header[7:]), true
+}
+
+// authenticateToken 校验 API 令牌及其所属用户
+func authenticateToken(c *gin.Context, store storage.Store, tokens *storage.Tokens, raw string) {
+	token, err := tokens.Authenticate(raw)
+	if err != nil {
+		if errors.Is(err, storage.ErrTokenInvalid) {
+			c.JSON(http.StatusUnauthorized, models.APIResponse{
+				Success: false,
+				Message: "令牌无效或已过期",
+			})
+		} else {
+			log.Printf("读取令牌失败: %v", err)
+			c.JSON(http.StatusInternalServerError, models.APIResponse{
+				Success: false,
+				Message: "读取令牌失败",
+			})
+		}
+		c.Abort()
+		return
+	}
+
+	user, err := findUser(store, token.Username)
+	if err != nil {
+		log.Printf("读取用户失败: %v", err)
+		c.JSON(http.StatusInternalServerError, models.APIResponse{
+			Success: false,
+			Message: "读取用户失败",
+		})
+		c.Abort()
+		return
+	}
+	if user == nil || user.Disabled {
+		c.JSON(http.StatusUnauthorized, models.APIResponse{
+			Success: false,
+			Message: "令牌所属账号不存在或已被禁用",
+		})
+		c.Abort()
+		return
+	}
+
+	c.Set(currentUserKey, user)
+	c.Set(apiTokenKey, &token)
+	c.Next()
+}
+
+// findUser 按用户名查找用户，不存在时返回 nil
+func findUser(store storage.Store, username string) (*models.User, error) {
+	users, err := store.GetUsers()
+	if err != nil {
+		return nil, err
+	}
+	for _, user := range users {
+		if user.Username == username {
+			return &user, nil
+		}
+	}
+	return nil, nil
+}
+
 // GetCurrentUser 获取当前登录用户信息
 func GetCurrentUser(c *gin.Context) *models.UserSession {
 	session := sessions.Default(c)
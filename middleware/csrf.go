@@ -0,0 +1,100 @@
+package middleware
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"log"
+	"net/http"
+	"strings"
+
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// csrfSessionKey 会话中保存 CSRF 令牌的键，以会话中的值为准
+	csrfSessionKey = "csrfToken"
+	// CSRFCookieName 供前端脚本读取的 CSRF 令牌 Cookie（非 HttpOnly）
+	CSRFCookieName = "navdesk_csrf"
+	// CSRFHeaderName 修改类请求需携带的请求头
+	CSRFHeaderName = "X-CSRF-Token"
+)
+
+// CSRF 校验修改类请求（POST、PUT、PATCH、DELETE）的 X-CSRF-Token 请求头，需在 sessions 中间件之后全局使用。
+// 令牌保存在签名的会话 Cookie 中，同时写入可被前端脚本读取的 navdesk_csrf Cookie；
+// 其他站点的页面读不到该 Cookie，也就无法伪造请求头。
+// 带有 Authorization: Bearer 请求头的修改类请求在这里校验令牌，令牌有效时请求以令牌所属用户认证、不依赖 Cookie，
+// 因此不再校验 CSRF 令牌；令牌无效时直接拒绝，不会因为带了该请求头而跳过校验
+func CSRF(store storage.Store, tokens *storage.Tokens) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := sessions.Default(c)
+		token, _ := session.Get(csrfSessionKey).(string)
+		if needsCSRFToken(c.Request.URL.Path) {
+			if token == "" {
+				token = setCSRFToken(c, session)
+				if err := session.Save(); err != nil {
+					log.Printf("Session保存失败: %v", err)
+				}
+			} else if cookie, err := c.Cookie(CSRFCookieName); err != nil || cookie != token {
+				// 前端可读的 Cookie 丢失或被替换时重新写入
+				writeCSRFCookie(c, token)
+			}
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		if raw, ok := bearerToken(c); ok {
+			authenticateToken(c, store, tokens, raw)
+			return
+		}
+
+		header := c.GetHeader(CSRFHeaderName)
+		if token == "" || subtle.ConstantTimeCompare([]byte(header), []byte(token)) != 1 {
+			log.Printf("CSRF 校验失败: ip=%s %s %s", c.ClientIP(), c.Request.Method, c.Request.URL.Path)
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Message: "请求校验失败，请刷新页面后重试",
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// setCSRFToken 生成新的 CSRF 令牌，写入会话与 navdesk_csrf Cookie，需在写入响应体之前调用，由调用方保存会话
+func setCSRFToken(c *gin.Context, session sessions.Session) string {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		log.Printf("生成 CSRF 令牌失败: %v", err)
+		return ""
+	}
+	token := base64.RawURLEncoding.EncodeToString(buf)
+
+	session.Set(csrfSessionKey, token)
+	writeCSRFCookie(c, token)
+	return token
+}
+
+func writeCSRFCookie(c *gin.Context, token string) {
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:     CSRFCookieName,
+		Value:    token,
+		Path:     "/",
+		Secure:   c.Request.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+}
+
+// needsCSRFToken 只为后台页面与接口生成令牌，避免前台访客都带上会话 Cookie
+func needsCSRFToken(path string) bool {
+	return strings.HasPrefix(path, "/admin") || strings.HasPrefix(path, "/api/auth")
+}
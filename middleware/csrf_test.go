@@ -0,0 +1,106 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+func newCSRFRouter(t *testing.T) (*gin.Engine, string) {
+	t.Helper()
+	store := storage.NewJSONStore(t.TempDir())
+	if err := store.SaveUsers(map[string]models.User{"admin": {Username: "admin", Role: models.RoleAdmin}}); err != nil {
+		t.Fatal(err)
+	}
+	tokens := storage.NewTokens(store)
+	_, raw, err := tokens.Create("admin", "脚本", []string{models.ScopeAdmin}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(sessions.Sessions("navdesk_session", cookie.NewStore([]byte("test"))))
+	r.Use(CSRF(store, tokens))
+	ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+	// 只使用会话 Cookie 的接口（如登录、登出）与接受令牌的接口
+	r.GET("/admin/login.html", ok)
+	r.POST("/api/auth/logout", ok)
+	r.POST("/api/bookmarks/", RequireAuth(store, tokens), func(c *gin.Context) {
+		if APIToken(c) == nil || CurrentUser(c).Username != "admin" {
+			t.Error("令牌认证的请求缺少令牌用户")
+		}
+		c.Status(http.StatusOK)
+	})
+	return r, raw
+}
+
+func TestCSRFBearerToken(t *testing.T) {
+	r, raw := newCSRFRouter(t)
+
+	tests := []struct {
+		name          string
+		path          string
+		authorization string
+		want          int
+	}{
+		{name: "没有 CSRF 令牌", path: "/api/auth/logout", want: http.StatusForbidden},
+		{name: "无效的 Bearer 令牌不能跳过校验", path: "/api/auth/logout", authorization: "Bearer invalid", want: http.StatusUnauthorized},
+		{name: "空的 Bearer 令牌不能跳过校验", path: "/api/auth/logout", authorization: "Bearer ", want: http.StatusUnauthorized},
+		{name: "有效的令牌", path: "/api/bookmarks/", authorization: "Bearer " + raw, want: http.StatusOK},
+		{name: "无效的令牌访问接口", path: "/api/bookmarks/", authorization: "Bearer invalid", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, tt.path, nil)
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d, body %s", w.Code, tt.want, w.Body)
+			}
+		})
+	}
+}
+
+func TestCSRFSessionToken(t *testing.T) {
+	r, _ := newCSRFRouter(t)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/admin/login.html", nil))
+	var token string
+	cookies := w.Result().Cookies()
+	for _, cookie := range cookies {
+		if cookie.Name == CSRFCookieName {
+			token = cookie.Value
+		}
+	}
+	if token == "" {
+		t.Fatal("未写入 CSRF Cookie")
+	}
+
+	for header, want := range map[string]int{token: http.StatusOK, "wrong": http.StatusForbidden} {
+		req := httptest.NewRequest(http.MethodPost, "/api/auth/logout", nil)
+		for _, cookie := range cookies {
+			req.AddCookie(cookie)
+		}
+		req.Header.Set(CSRFHeaderName, header)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		if w.Code != want {
+			t.Errorf("X-CSRF-Token=%q: status %d, want %d", header, w.Code, want)
+		}
+	}
+}
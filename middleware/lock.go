@@ -0,0 +1,30 @@
+package middleware
+
+import (
+	"log"
+	"net/http"
+
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WriteLock 写操作串行化中间件，请求处理期间持有存储写锁，
+// 保证处理器中"读取-修改-保存"的过程不会与其他写请求交错
+func WriteLock(store storage.Store) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if err := store.Lock(); err != nil {
+			log.Printf("获取数据写锁失败: %v", err)
+			c.JSON(http.StatusServiceUnavailable, models.APIResponse{
+				Success: false,
+				Message: "数据正忙，请稍后重试",
+			})
+			c.Abort()
+			return
+		}
+		defer store.Unlock()
+
+		c.Next()
+	}
+}
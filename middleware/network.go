@@ -0,0 +1,80 @@
+package middleware
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"navdesk/models"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ParseCIDRs 解析逗号分隔的网段列表，单个 IP 视为 /32（IPv6 为 /128）
+func ParseCIDRs(value string) ([]*net.IPNet, error) {
+	var networks []*net.IPNet
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		if !strings.Contains(item, "/") {
+			ip := net.ParseIP(item)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的 IP 地址: %s", item)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip = ip.To4()
+				bits = 32
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+
+		_, network, err := net.ParseCIDR(item)
+		if err != nil {
+			return nil, fmt.Errorf("无效的网段: %s", item)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// AllowNetworks 只允许来源 IP 位于指定网段内的请求，networks 为空时不做限制。
+// 来源 IP 取自 gin 的 ClientIP，经过反向代理时需配置可信代理
+func AllowNetworks(networks []*net.IPNet) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if len(networks) == 0 || containsIP(networks, c.ClientIP()) {
+			c.Next()
+			return
+		}
+
+		log.Printf("navdesk access denied: ip=%s path=%q", c.ClientIP(), c.Request.URL.Path)
+		if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+			c.JSON(http.StatusForbidden, models.APIResponse{
+				Success: false,
+				Message: "当前网络不允许访问管理功能",
+			})
+		} else {
+			c.String(http.StatusForbidden, "当前网络不允许访问管理功能")
+		}
+		c.Abort()
+	}
+}
+
+func containsIP(networks []*net.IPNet, value string) bool {
+	ip := net.ParseIP(value)
+	if ip == nil {
+		return false
+	}
+	for _, network := range networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
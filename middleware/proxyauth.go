@@ -0,0 +1,96 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"net"
+	"strings"
+
+	"navdesk/auth"
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+// proxyIdentityKey 会话中记录最近一次处理过的代理身份（用户名与组），身份不变时不再重复处理
+const proxyIdentityKey = "proxyIdentity"
+
+// ProxyAuthConfig 反向代理认证配置
+type ProxyAuthConfig struct {
+	// Networks 可信代理的网段，只有直接来自这些地址的请求才读取身份请求头
+	Networks []*net.IPNet
+	// UserHeader 用户名请求头，如 Remote-User
+	UserHeader string
+	// GroupsHeader 逗号分隔的用户组请求头，如 Remote-Groups
+	GroupsHeader string
+	// RoleMap 组名到角色的映射，匹配多个时取最高角色
+	RoleMap map[string]string
+	// DefaultRole 不属于任何映射组时使用的角色，为空表示拒绝登录
+	DefaultRole string
+}
+
+// ProxyAuth 信任 Authelia、oauth2-proxy 等认证代理转发的身份请求头，需在 ServerSessions 之后全局使用。
+// 请求直接来自可信代理且带有用户名请求头时，按所属组映射角色、创建或同步账号并建立会话，
+// 之后 RequireAuth 与后台页面都按普通会话处理。来自其他地址的同名请求头一律忽略
+func ProxyAuth(store storage.Store, registry *storage.Sessions, config ProxyAuthConfig) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		username := strings.TrimSpace(c.GetHeader(config.UserHeader))
+		if username == "" {
+			c.Next()
+			return
+		}
+
+		// 只看 TCP 连接的对端地址，不使用可被伪造的 X-Forwarded-For
+		if !containsIP(config.Networks, c.RemoteIP()) {
+			log.Printf("忽略来自非可信代理 %s 的 %s 请求头", c.RemoteIP(), config.UserHeader)
+			c.Next()
+			return
+		}
+
+		groupsHeader := c.GetHeader(config.GroupsHeader)
+		identity := username + "\n" + groupsHeader
+		session := sessions.Default(c)
+		if session.Get(proxyIdentityKey) == identity {
+			c.Next()
+			return
+		}
+
+		var groups []string
+		for _, group := range strings.Split(groupsHeader, ",") {
+			if group = strings.TrimSpace(group); group != "" {
+				groups = append(groups, group)
+			}
+		}
+		role := auth.MapRole(groups, config.RoleMap)
+		if role == "" {
+			role = config.DefaultRole
+		}
+
+		// 代理切换了用户或用户组发生变化：注销旧会话，重新建立
+		EndSession(c, registry)
+		session.Set(proxyIdentityKey, identity)
+
+		user, err := auth.ProvisionUser(store, models.ProviderProxy, username, role, true, true)
+		switch {
+		case errors.Is(err, auth.ErrAccessDenied), errors.Is(err, auth.ErrAccountDisabled):
+			// 保持未登录状态，需要登录的接口由 RequireAuth 拒绝
+		case err != nil:
+			log.Printf("反向代理认证失败: %s - %v", username, err)
+			session.Delete(proxyIdentityKey)
+		default:
+			if err := StartSession(c, registry, user); err != nil {
+				log.Printf("反向代理认证创建会话失败: %s - %v", username, err)
+				session.Delete(proxyIdentityKey)
+			} else {
+				log.Printf("反向代理认证登录成功: %s (%s)", user.Username, user.Role)
+			}
+		}
+		if err := session.Save(); err != nil {
+			log.Printf("Session保存失败: %v", err)
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,97 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"navdesk/auth"
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-contrib/sessions/cookie"
+	"github.com/gin-gonic/gin"
+)
+
+func newProxyAuthRouter(t *testing.T) (*gin.Engine, storage.Store) {
+	t.Helper()
+	store := storage.NewJSONStore(t.TempDir())
+	if err := store.SaveUsers(map[string]models.User{"admin": {Username: "admin", Role: models.RoleAdmin}}); err != nil {
+		t.Fatal(err)
+	}
+	networks, err := ParseCIDRs("10.0.0.0/8")
+	if err != nil {
+		t.Fatal(err)
+	}
+	roleMap, err := auth.ParseRoleMap("navdesk-admins=admin,staff=editor")
+	if err != nil {
+		t.Fatal(err)
+	}
+	registry := storage.NewSessions(store, time.Hour, 0)
+
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(sessions.Sessions("navdesk_session", cookie.NewStore([]byte("test"))))
+	r.Use(ServerSessions(registry))
+	r.Use(ProxyAuth(store, registry, ProxyAuthConfig{
+		Networks:     networks,
+		UserHeader:   "Remote-User",
+		GroupsHeader: "Remote-Groups",
+		RoleMap:      roleMap,
+	}))
+	r.GET("/api/whoami", RequireAuth(store, storage.NewTokens(store)), func(c *gin.Context) {
+		user := CurrentUser(c)
+		c.String(http.StatusOK, user.Username+" "+user.Role)
+	})
+	return r, store
+}
+
+func TestProxyAuth(t *testing.T) {
+	tests := []struct {
+		name       string
+		remoteAddr string
+		user       string
+		groups     string
+		want       int
+		wantBody   string
+	}{
+		{name: "非可信地址伪造已有账号", remoteAddr: "192.0.2.10:40000", user: "admin", groups: "navdesk-admins", want: http.StatusUnauthorized},
+		{name: "非可信地址伪造新账号", remoteAddr: "192.0.2.10:40000", user: "mallory", groups: "navdesk-admins", want: http.StatusUnauthorized},
+		{name: "可信代理按组映射角色", remoteAddr: "10.1.2.3:40000", user: "alice", groups: "staff", want: http.StatusOK, wantBody: "alice editor"},
+		{name: "可信代理匹配多个组取最高角色", remoteAddr: "10.1.2.3:40000", user: "bob", groups: "staff, navdesk-admins", want: http.StatusOK, wantBody: "bob admin"},
+		{name: "可信代理没有匹配的组", remoteAddr: "10.1.2.3:40000", user: "carol", groups: "guests", want: http.StatusUnauthorized},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r, store := newProxyAuthRouter(t)
+			req := httptest.NewRequest(http.MethodGet, "/api/whoami", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("Remote-User", tt.user)
+			req.Header.Set("Remote-Groups", tt.groups)
+			// 代理前的伪造来源地址不应被采信
+			req.Header.Set("X-Forwarded-For", "10.1.2.3")
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			if w.Code != tt.want {
+				t.Fatalf("status %d, want %d, body %s", w.Code, tt.want, w.Body)
+			}
+			if tt.wantBody != "" && w.Body.String() != tt.wantBody {
+				t.Fatalf("当前用户 %q，want %q", w.Body, tt.wantBody)
+			}
+			if tt.want != http.StatusOK {
+				// 未认证的请求不能创建账号
+				users, err := store.GetUsers()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if len(users) != 1 {
+					t.Fatalf("账号列表 %v，want 只有 admin", users)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package middleware
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// DefaultContentSecurityPolicy 默认内容安全策略。现有页面使用内联脚本、样式和 onclick 属性，
+	// 因此允许 'unsafe-inline'；书签图标可能来自任意 http、https 站点
+	DefaultContentSecurityPolicy = "default-src 'self'; script-src 'self' 'unsafe-inline'; style-src 'self' 'unsafe-inline'; " +
+		"img-src 'self' data: http: https:; connect-src 'self'; object-src 'none'; base-uri 'self'; form-action 'self'"
+	// DefaultReferrerPolicy 默认来源策略：跨站只发送源站地址
+	DefaultReferrerPolicy = "strict-origin-when-cross-origin"
+	// DefaultPermissionsPolicy 默认关闭页面用不到的浏览器功能
+	DefaultPermissionsPolicy = "camera=(), microphone=(), geolocation=(), payment=(), usb=()"
+)
+
+// SecurityHeadersConfig 安全响应头配置，字符串为空表示不发送对应的响应头
+type SecurityHeadersConfig struct {
+	// ContentSecurityPolicy 内容安全策略
+	ContentSecurityPolicy string
+	// CSPReportOnly 以 Content-Security-Policy-Report-Only 发送，只报告违规、不拦截，用于调整策略
+	CSPReportOnly bool
+	// CSPReportURI 违规报告的接收地址，追加为 report-uri 指令
+	CSPReportURI string
+	// FrameOptions X-Frame-Options 取值：DENY 或 SAMEORIGIN，同时追加对应的 frame-ancestors 指令
+	FrameOptions string
+	// ReferrerPolicy Referrer-Policy 取值
+	ReferrerPolicy string
+	// PermissionsPolicy Permissions-Policy 取值
+	PermissionsPolicy string
+	// HSTSMaxAge Strict-Transport-Security 的有效期，0 表示不发送；只在 HTTPS 请求中发送
+	HSTSMaxAge time.Duration
+	// HSTSIncludeSubdomains HSTS 是否包含子域名
+	HSTSIncludeSubdomains bool
+}
+
+// SecurityHeaders 为所有响应添加安全相关的响应头，需全局使用。
+// 处理器或路由组中间件可以覆盖（如 UploadHeaders 为上传文件设置更严格的 CSP）
+func SecurityHeaders(config SecurityHeadersConfig) (gin.HandlerFunc, error) {
+	csp := config.ContentSecurityPolicy
+	frameOptions := strings.ToUpper(config.FrameOptions)
+	switch frameOptions {
+	case "":
+	case "DENY":
+		csp = appendCSPDirective(csp, "frame-ancestors 'none'")
+	case "SAMEORIGIN":
+		csp = appendCSPDirective(csp, "frame-ancestors 'self'")
+	default:
+		return nil, fmt.Errorf("无效的 X-Frame-Options 取值: %s（可选 DENY、SAMEORIGIN）", config.FrameOptions)
+	}
+	if config.CSPReportURI != "" {
+		csp = appendCSPDirective(csp, "report-uri "+config.CSPReportURI)
+	}
+
+	cspHeader := "Content-Security-Policy"
+	if config.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+
+	hsts := ""
+	if config.HSTSMaxAge > 0 {
+		hsts = "max-age=" + strconv.FormatInt(int64(config.HSTSMaxAge/time.Second), 10)
+		if config.HSTSIncludeSubdomains {
+			hsts += "; includeSubDomains"
+		}
+	}
+
+	return func(c *gin.Context) {
+		header := c.Writer.Header()
+		if config.ContentSecurityPolicy != "" {
+			header.Set(cspHeader, csp)
+		}
+		if frameOptions != "" {
+			header.Set("X-Frame-Options", frameOptions)
+		}
+		if config.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", config.ReferrerPolicy)
+		}
+		if config.PermissionsPolicy != "" {
+			header.Set("Permissions-Policy", config.PermissionsPolicy)
+		}
+		header.Set("X-Content-Type-Options", "nosniff")
+		// 浏览器只认可 HTTPS 响应中的 HSTS，伪造的 X-Forwarded-Proto 不会产生效果
+		if hsts != "" && (c.Request.TLS != nil || strings.EqualFold(c.GetHeader("X-Forwarded-Proto"), "https")) {
+			header.Set("Strict-Transport-Security", hsts)
+		}
+		c.Next()
+	}, nil
+}
+
+// appendCSPDirective 在策略中追加指令，策略中已有同名指令时保持不变
+func appendCSPDirective(policy, directive string) string {
+	if policy == "" {
+		return ""
+	}
+	name := strings.Fields(directive)[0]
+	for _, existing := range strings.Split(policy, ";") {
+		if fields := strings.Fields(existing); len(fields) > 0 && strings.EqualFold(fields[0], name) {
+			return policy
+		}
+	}
+	return strings.TrimRight(strings.TrimSpace(policy), ";") + "; " + directive
+}
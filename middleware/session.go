@@ -0,0 +1,110 @@
+package middleware
+
+import (
+	"errors"
+	"log"
+	"time"
+
+	"navdesk/models"
+	"navdesk/storage"
+
+	"github.com/gin-contrib/sessions"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// sessionSecretKey Cookie 中保存服务端会话密钥的键
+	sessionSecretKey = "sid"
+	// sessionIDKey 请求上下文中当前会话 ID 的键
+	sessionIDKey = "sessionID"
+)
+
+// ServerSessions 核对登录 Cookie 对应的服务端会话，需在 sessions 中间件之后全局使用。
+// 会话被注销、空闲超时或不属于 Cookie 中的用户时清除登录状态，后续的页面与接口都视为未登录
+func ServerSessions(registry *storage.Sessions) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session := sessions.Default(c)
+		username, _ := session.Get("username").(string)
+		if username == "" {
+			c.Next()
+			return
+		}
+
+		raw, _ := session.Get(sessionSecretKey).(string)
+		record, err := registry.Authenticate(raw, c.ClientIP())
+		if err == nil && record.Username == username {
+			c.Set(sessionIDKey, record.ID)
+			c.Next()
+			return
+		}
+
+		if err != nil && !errors.Is(err, storage.ErrSessionInvalid) {
+			// 读取失败时只对本次请求视为未登录，不删除 Cookie
+			log.Printf("读取会话失败: %v", err)
+			clearLogin(session)
+			c.Next()
+			return
+		}
+
+		clearLogin(session)
+		if err := session.Save(); err != nil {
+			log.Printf("Session保存失败: %v", err)
+		}
+		c.Next()
+	}
+}
+
+// StartSession 创建服务端会话并写入登录 Cookie，密码登录、单点登录与反向代理认证共用。
+// 同一浏览器中已有的会话会被注销
+func StartSession(c *gin.Context, registry *storage.Sessions, user *models.User) error {
+	if id := SessionID(c); id != "" {
+		registry.Revoke(id)
+	}
+
+	record, raw, err := registry.Create(user.Username, c.Request.UserAgent(), c.ClientIP())
+	if err != nil {
+		return err
+	}
+
+	// 登录后更换 CSRF 令牌，防止登录前泄露的令牌继续可用
+	session := sessions.Default(c)
+	setCSRFToken(c, session)
+	session.Set("username", user.Username)
+	session.Set("role", user.Role)
+	session.Set("loginTime", time.Now().Format(time.RFC3339))
+	session.Set(sessionSecretKey, raw)
+	if err := session.Save(); err != nil {
+		registry.Revoke(record.ID)
+		return err
+	}
+
+	c.Set(sessionIDKey, record.ID)
+	return nil
+}
+
+// EndSession 注销当前服务端会话并清除登录 Cookie
+func EndSession(c *gin.Context, registry *storage.Sessions) {
+	if id := SessionID(c); id != "" {
+		if err := registry.Revoke(id); err != nil && !errors.Is(err, storage.ErrSessionNotFound) {
+			log.Printf("注销会话失败: %v", err)
+		}
+		c.Set(sessionIDKey, "")
+	}
+
+	session := sessions.Default(c)
+	clearLogin(session)
+	session.Save()
+}
+
+// clearLogin 清除会话中的登录信息，保留 CSRF 令牌等其他状态
+func clearLogin(session sessions.Session) {
+	session.Delete("username")
+	session.Delete("role")
+	session.Delete("loginTime")
+	session.Delete(sessionSecretKey)
+}
+
+// SessionID 返回当前请求的服务端会话 ID，未登录或使用 API 令牌时返回空字符串
+func SessionID(c *gin.Context) string {
+	return c.GetString(sessionIDKey)
+}
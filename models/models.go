@@ -1,15 +1,86 @@
 package models
 
 import (
+	"encoding/json"
 	"time"
 )
 
+// 用户角色
+const (
+	RoleAdmin  = "admin"
+	RoleEditor = "editor"
+	RoleViewer = "viewer"
+)
+
+// 外部身份来源
+const (
+	ProviderOIDC  = "oidc"
+	ProviderLDAP  = "ldap"
+	ProviderProxy = "proxy"
+)
+
 // User 用户模型
 type User struct {
 	Username  string    `json:"username"`
 	Password  string    `json:"password"`
 	Role      string    `json:"role"`
+	Disabled  bool      `json:"disabled,omitempty"`
 	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+
+	// Provider 外部身份来源（如 oidc），为空表示本地账号
+	Provider string `json:"provider,omitempty"`
+	// ExternalIssuer、ExternalSubject 关联的单点登录身份（ID Token 的 iss 与 sub），登录时按此查找账号
+	ExternalIssuer  string `json:"externalIssuer,omitempty"`
+	ExternalSubject string `json:"externalSubject,omitempty"`
+
+	// 两步验证：TOTPSecret 非空但 TOTPEnabled 为 false 表示正在绑定、尚未确认
+	TOTPSecret    string   `json:"totpSecret,omitempty"`
+	TOTPEnabled   bool     `json:"totpEnabled,omitempty"`
+	TOTPLastStep  int64    `json:"totpLastStep,omitempty"`
+	RecoveryCodes []string `json:"recoveryCodes,omitempty"`
+}
+
+// UserInfo 返回给客户端的用户信息，不包含密码
+type UserInfo struct {
+	Username    string    `json:"username"`
+	Role        string    `json:"role"`
+	Disabled    bool      `json:"disabled"`
+	TOTPEnabled bool      `json:"totpEnabled"`
+	Provider    string    `json:"provider,omitempty"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt,omitempty"`
+}
+
+// API 令牌权限范围，admin 包含 bookmarks:write，bookmarks:write 包含 bookmarks:read
+const (
+	ScopeBookmarksRead  = "bookmarks:read"
+	ScopeBookmarksWrite = "bookmarks:write"
+	ScopeAdmin          = "admin"
+)
+
+// APIToken 个人 API 令牌，令牌本身只在创建时返回一次，保存的是其哈希
+type APIToken struct {
+	ID         string     `json:"id"`
+	Name       string     `json:"name"`
+	Username   string     `json:"username"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// Session 服务端登录会话，Cookie 中只保存会话密钥，服务端保存其哈希
+type Session struct {
+	ID         string    `json:"id"`
+	Username   string    `json:"username"`
+	UserAgent  string    `json:"userAgent"`
+	IP         string    `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastSeenAt time.Time `json:"lastSeenAt"`
+	// Current 是否为发起请求的会话，只在列表中返回
+	Current bool `json:"current,omitempty"`
 }
 
 // Category 分类模型
@@ -19,6 +90,7 @@ type Category struct {
 	Icon      string    `json:"icon"`
 	UploadDir string    `json:"uploadDir"`
 	Sort      int       `json:"sort"`
+	Version   int       `json:"version"`
 	CreatedAt time.Time `json:"createdAt"`
 	UpdatedAt time.Time `json:"updatedAt,omitempty"`
 }
@@ -33,6 +105,7 @@ type Bookmark struct {
 	Category    string    `json:"category"`
 	Tags        []string  `json:"tags"`
 	Sort        int       `json:"sort"`
+	Version     int       `json:"version"`
 	CreatedAt   time.Time `json:"createdAt"`
 	UpdatedAt   time.Time `json:"updatedAt,omitempty"`
 }
@@ -46,14 +119,22 @@ type Settings struct {
 	IconHeight   int       `json:"iconHeight"`
 	SidebarWidth int       `json:"sidebarWidth"`
 	Theme        string    `json:"theme"`
+	Version      int       `json:"version"`
 	UpdatedAt    time.Time `json:"updatedAt"`
 }
 
 // APIResponse 通用API响应
 type APIResponse struct {
-	Success bool        `json:"success"`
-	Message string      `json:"message,omitempty"`
-	Data    interface{} `json:"data,omitempty"`
+	Success bool         `json:"success"`
+	Message string       `json:"message,omitempty"`
+	Data    interface{}  `json:"data,omitempty"`
+	Errors  []FieldError `json:"errors,omitempty"`
+}
+
+// FieldError 字段级校验错误
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
 }
 
 // DataResponse 数据接口响应
@@ -125,6 +206,58 @@ type UpdateSettingsRequest struct {
 	Theme        string `json:"theme" binding:"required"`
 }
 
+// CreateUserRequest 创建用户请求
+type CreateUserRequest struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+	Role     string `json:"role" binding:"required"`
+}
+
+// UpdateUserRequest 更新用户请求，未提供的字段保持不变；Password 非空时重置密码，
+// ResetTwoFactor 为 true 时关闭该用户的两步验证（用于丢失验证器且没有恢复码的情况）
+type UpdateUserRequest struct {
+	Role           *string `json:"role"`
+	Disabled       *bool   `json:"disabled"`
+	Password       string  `json:"password"`
+	ResetTwoFactor bool    `json:"resetTwoFactor"`
+}
+
+// ChangePasswordRequest 修改当前用户密码请求
+type ChangePasswordRequest struct {
+	OldPassword string `json:"oldPassword" binding:"required"`
+	NewPassword string `json:"newPassword" binding:"required"`
+}
+
+// TwoFactorCodeRequest 两步验证码请求，Code 可以是验证器中的 6 位数字或恢复码
+type TwoFactorCodeRequest struct {
+	Code string `json:"code" binding:"required"`
+}
+
+// TwoFactorPasswordRequest 需要再次输入密码确认的两步验证操作
+type TwoFactorPasswordRequest struct {
+	Password string `json:"password" binding:"required"`
+}
+
+// TwoFactorSetupResponse 开始绑定两步验证的响应
+type TwoFactorSetupResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+	QRCode string `json:"qrCode"` // PNG 格式的 data URI
+}
+
+// CreateTokenRequest 创建 API 令牌请求，ExpiresInDays 为 0 表示永不过期
+type CreateTokenRequest struct {
+	Name          string   `json:"name" binding:"required"`
+	Scopes        []string `json:"scopes" binding:"required"`
+	ExpiresInDays int      `json:"expiresInDays"`
+}
+
+// CreateTokenResponse 创建 API 令牌响应，Token 只返回这一次
+type CreateTokenResponse struct {
+	APIToken
+	Token string `json:"token"`
+}
+
 // UploadResponse 上传响应
 type UploadResponse struct {
 	URL          string `json:"url"`
@@ -132,3 +265,77 @@ type UploadResponse struct {
 	OriginalName string `json:"originalname"`
 	Size         int64  `json:"size"`
 }
+
+// Snapshot 数据快照信息
+type Snapshot struct {
+	ID        string    `json:"id"`
+	Reason    string    `json:"reason"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// 回收站条目类型
+const (
+	TrashTypeBookmark = "bookmark"
+	TrashTypeCategory = "category"
+)
+
+// TrashItem 回收站条目。删除分类时，其下的书签一并记录在 Bookmarks 中
+type TrashItem struct {
+	ID        string     `json:"id"`
+	Type      string     `json:"type"`
+	Name      string     `json:"name"`
+	Category  *Category  `json:"category,omitempty"`
+	Bookmarks []Bookmark `json:"bookmarks"`
+	DeletedBy string     `json:"deletedBy"`
+	DeletedAt time.Time  `json:"deletedAt"`
+	PurgeAt   *time.Time `json:"purgeAt,omitempty"`
+}
+
+// Revision 资源修订记录。Data 为该次修改之前的完整内容，ChangedBy/ChangedAt 为修改者与修改时间
+type Revision struct {
+	Revision     int             `json:"revision"`
+	ResourceType string          `json:"resourceType"`
+	ResourceID   string          `json:"resourceId"`
+	Data         json.RawMessage `json:"data"`
+	ChangedBy    string          `json:"changedBy"`
+	ChangedAt    time.Time       `json:"changedAt"`
+}
+
+// FieldChange 字段级差异
+type FieldChange struct {
+	Field string      `json:"field"`
+	Old   interface{} `json:"old"`
+	New   interface{} `json:"new"`
+}
+
+// RevisionDetail 修订详情，Changes 为该次修改涉及的字段变化
+type RevisionDetail struct {
+	Revision
+	Changes []FieldChange `json:"changes"`
+}
+
+// 数据完整性问题类型
+const (
+	IssueOrphanBookmark   = "orphan-bookmark"
+	IssueUnreferencedFile = "unreferenced-file"
+	IssueMissingIcon      = "missing-icon"
+	IssueDuplicateID      = "duplicate-id"
+	IssueSharedUploadDir  = "shared-upload-dir"
+)
+
+// IntegrityIssue 数据完整性问题。Path 为 uploads 下的相对路径，Repaired 表示已在本次检查中修复
+type IntegrityIssue struct {
+	Type       string `json:"type"`
+	ResourceID string `json:"resourceId,omitempty"`
+	Path       string `json:"path,omitempty"`
+	Message    string `json:"message"`
+	Repaired   bool   `json:"repaired"`
+}
+
+// IntegrityReport 数据完整性检查报告
+type IntegrityReport struct {
+	Repair    bool             `json:"repair"`
+	Issues    []IntegrityIssue `json:"issues"`
+	CheckedAt time.Time        `json:"checkedAt"`
+}
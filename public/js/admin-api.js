@@ -0,0 +1,48 @@
+// CSRF 防护：同源的修改类请求自动携带 X-CSRF-Token 请求头，令牌取自服务端写入的 navdesk_csrf Cookie
+(function () {
+    const originalFetch = window.fetch;
+    const safeMethods = ['GET', 'HEAD', 'OPTIONS'];
+
+    function csrfToken() {
+        const match = document.cookie.match(/(?:^|;\s*)navdesk_csrf=([^;]*)/);
+        return match ? decodeURIComponent(match[1]) : '';
+    }
+
+    window.fetch = function (input, init = {}) {
+        const url = new URL(input instanceof Request ? input.url : input, window.location.href);
+        const method = (init.method || (input instanceof Request ? input.method : 'GET')).toUpperCase();
+        if (url.origin === window.location.origin && !safeMethods.includes(method)) {
+            const headers = new Headers(init.headers || (input instanceof Request ? input.headers : undefined));
+            if (!headers.has('X-CSRF-Token')) {
+                headers.set('X-CSRF-Token', csrfToken());
+            }
+            init = { ...init, headers };
+        }
+        return originalFetch.call(this, input, init);
+    };
+})();
+
+// 带版本校验的请求：通过 If-Match 提交编辑时看到的版本号，
+// 数据已被其他人修改（412）时提示用户是否覆盖。
+// 用户放弃覆盖时返回 null，调用方应重新加载数据
+async function fetchWithVersion(url, options, version, label) {
+    const headers = { ...(options.headers || {}) };
+    if (version) {
+        headers['If-Match'] = `"${version}"`;
+    }
+
+    const response = await fetch(url, { ...options, headers });
+    if (response.status !== 412) {
+        return response;
+    }
+
+    const result = await response.json();
+    const current = result.data || {};
+    const name = current.name || current.siteTitle || '';
+    const message = `该${label}${name ? `「${name}」` : ''}已被其他人修改。\n\n` +
+        '点击“确定”用你的内容覆盖，点击“取消”放弃并重新加载最新数据。';
+    if (!confirm(message)) {
+        return null;
+    }
+    return fetchWithVersion(url, options, current.version, label);
+}
@@ -0,0 +1,208 @@
+package storage
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"navdesk/models"
+)
+
+// watchInterval 数据文件变更检测间隔
+const watchInterval = 2 * time.Second
+
+// fileStamp 文件修改时间与大小，用于判断文件是否被外部修改。
+// 数据文件通过重命名原子替换，file 用于识别修改时间精度内的替换
+type fileStamp struct {
+	modTime time.Time
+	size    int64
+	file    os.FileInfo
+}
+
+func stampOf(info os.FileInfo) fileStamp {
+	return fileStamp{
+		modTime: info.ModTime(),
+		size:    info.Size(),
+		file:    info,
+	}
+}
+
+// changed 判断文件当前状态与记录时是否不同
+func (s fileStamp) changed(info os.FileInfo) bool {
+	return s.file == nil || !os.SameFile(s.file, info) ||
+		!info.ModTime().Equal(s.modTime) || info.Size() != s.size
+}
+
+// cacheEntry 单个数据文件解析后的内存副本
+type cacheEntry struct {
+	stamp fileStamp
+	value interface{}
+}
+
+// usersDoc users.json 解析结果
+type usersDoc struct {
+	users     map[string]models.User
+	secretKey string
+}
+
+// jsonParsers 各数据文件的解析函数
+var jsonParsers = map[string]func([]byte) (interface{}, error){
+	usersFile: func(data []byte) (interface{}, error) {
+		users, err := parseUsers(data)
+		if err != nil {
+			return nil, err
+		}
+		secretKey, err := parseSecretKey(data)
+		if err != nil {
+			return nil, err
+		}
+		return usersDoc{users: users, secretKey: secretKey}, nil
+	},
+	categoriesFile: func(data []byte) (interface{}, error) {
+		var categories []models.Category
+		if err := json.Unmarshal(data, &categories); err != nil {
+			return nil, err
+		}
+		return categories, nil
+	},
+	bookmarksFile: func(data []byte) (interface{}, error) {
+		var bookmarks []models.Bookmark
+		if err := json.Unmarshal(data, &bookmarks); err != nil {
+			return nil, err
+		}
+		return bookmarks, nil
+	},
+	settingsFile: func(data []byte) (interface{}, error) {
+		var settings models.Settings
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return nil, err
+		}
+		return settings, nil
+	},
+}
+
+// cached 返回数据文件的内存副本，未缓存时从磁盘读取。
+// 每次读取都核对文件状态：其他进程持有写锁修改文件后，
+// 本进程取得写锁时读到的是最新数据，不会覆盖对方的修改
+func (s *JSONStore) cached(name string) (interface{}, error) {
+	s.cacheMu.RLock()
+	entry := s.cache[name]
+	s.cacheMu.RUnlock()
+
+	if entry == nil {
+		return s.reload(name)
+	}
+
+	info, err := os.Stat(filepath.Join(s.dataPath, name))
+	if err != nil || !entry.stamp.changed(info) {
+		// 文件被删除或暂时不可访问时继续使用内存中的数据
+		return entry.value, nil
+	}
+	return s.refresh(name, entry, info), nil
+}
+
+// reload 从磁盘读取并解析数据文件，成功后更新缓存
+func (s *JSONStore) reload(name string) (interface{}, error) {
+	path := filepath.Join(s.dataPath, name)
+	// 先取文件状态再读取内容，读取期间若文件再次变化，下一轮检测会重新加载
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := jsonParsers[name](data)
+	if err != nil {
+		return nil, err
+	}
+
+	s.setCache(name, stampOf(info), value)
+	return value, nil
+}
+
+// remember 写入文件后更新缓存，避免下一次读取重新解析
+func (s *JSONStore) remember(name string, value interface{}) {
+	info, err := os.Stat(filepath.Join(s.dataPath, name))
+	if err != nil {
+		s.invalidate(name)
+		return
+	}
+	s.setCache(name, stampOf(info), value)
+}
+
+func (s *JSONStore) setCache(name string, stamp fileStamp, value interface{}) {
+	s.cacheMu.Lock()
+	s.cache[name] = &cacheEntry{stamp: stamp, value: value}
+	s.cacheMu.Unlock()
+}
+
+// invalidate 丢弃数据文件的缓存，下一次读取时从磁盘加载
+func (s *JSONStore) invalidate(name string) {
+	s.cacheMu.Lock()
+	delete(s.cache, name)
+	s.cacheMu.Unlock()
+}
+
+// Watch 定期检测数据目录中的 JSON 文件，被手工修改时自动重新加载。
+// 文件内容无法解析时保留上一次的有效数据并记录错误
+func (s *JSONStore) Watch(interval time.Duration) {
+	stop := make(chan struct{})
+	s.stopWatch = stop
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				s.checkFiles()
+			case <-stop:
+				return
+			}
+		}
+	}()
+}
+
+// checkFiles 检查已缓存的数据文件是否被外部修改
+func (s *JSONStore) checkFiles() {
+	for name := range jsonParsers {
+		s.cacheMu.RLock()
+		entry := s.cache[name]
+		s.cacheMu.RUnlock()
+
+		// 尚未加载过的文件在下一次读取时自然会从磁盘读取
+		if entry == nil {
+			continue
+		}
+
+		info, err := os.Stat(filepath.Join(s.dataPath, name))
+		if err != nil {
+			// 文件被删除或暂时不可访问，继续使用内存中的数据
+			continue
+		}
+
+		if entry.stamp.changed(info) {
+			s.refresh(name, entry, info)
+		}
+	}
+}
+
+// refresh 重新加载已被修改的数据文件。文件内容无法解析时保留上一次的有效数据，
+// 并记录新的文件状态，文件再次修改前不重复报错
+func (s *JSONStore) refresh(name string, entry *cacheEntry, info os.FileInfo) interface{} {
+	value, err := s.reload(name)
+	if err != nil {
+		log.Printf("重新加载 %s 失败，继续使用上一次的有效数据: %v", name, err)
+		s.setCache(name, stampOf(info), entry.value)
+		return entry.value
+	}
+	log.Printf("检测到 %s 已被修改，已重新加载", name)
+	return value
+}
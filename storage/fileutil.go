@@ -0,0 +1,79 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// dataFileMode 返回数据文件的权限：users.json 含有密码哈希与会话密钥，只允许属主读写
+func dataFileMode(name string) os.FileMode {
+	if name == usersFile {
+		return 0600
+	}
+	return 0644
+}
+
+// writeFileAtomic 原子写入文件：先写入同目录下的临时文件并 fsync，
+// 再通过 rename 替换目标文件，避免进程崩溃时留下被截断的数据文件
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	dir := filepath.Dir(path)
+	tmp, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	// 任意步骤失败都清理临时文件
+	success := false
+	defer func() {
+		if !success {
+			tmp.Close()
+			os.Remove(tmpPath)
+		}
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpPath, perm); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		return err
+	}
+	success = true
+
+	// 同步目录项，确保 rename 本身落盘
+	return syncDir(dir)
+}
+
+// copyFile 复制文件内容并同步到磁盘，目标文件使用 perm 权限
+func copyFile(src, dst string, perm os.FileMode) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
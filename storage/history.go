@@ -0,0 +1,248 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"navdesk/models"
+)
+
+const historyDir = "history"
+
+// 修订历史支持的资源类型
+const (
+	HistoryBookmark = "bookmark"
+	HistoryCategory = "category"
+	HistorySettings = "settings"
+)
+
+// SettingsResourceID 设置在修订历史中使用的资源ID
+const SettingsResourceID = "settings"
+
+// ErrRevisionNotFound 修订记录不存在
+var ErrRevisionNotFound = errors.New("修订记录不存在")
+
+// diffIgnoredFields 计算差异时忽略的字段
+var diffIgnoredFields = map[string]bool{
+	"updatedAt": true,
+	"version":   true,
+}
+
+// History 书签、分类与设置的修订历史。
+// 每个资源的修订保存在 data/history/<类型>/<ID>.json 中
+type History struct {
+	dir   string
+	limit int
+}
+
+// NewHistory 创建修订历史，limit 为每个资源保留的修订数量（0 表示不限制）
+func NewHistory(store Store, limit int) *History {
+	return &History{
+		dir:   filepath.Join(store.GetDataPath(), historyDir),
+		limit: limit,
+	}
+}
+
+// Record 记录一次修改前的内容，调用方需持有写锁
+func (h *History) Record(resourceType, resourceID string, previous interface{}, changedBy string) error {
+	data, err := json.Marshal(previous)
+	if err != nil {
+		return err
+	}
+
+	revisions, err := h.List(resourceType, resourceID)
+	if err != nil {
+		return err
+	}
+
+	next := 1
+	if len(revisions) > 0 {
+		next = revisions[len(revisions)-1].Revision + 1
+	}
+
+	revisions = append(revisions, models.Revision{
+		Revision:     next,
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Data:         data,
+		ChangedBy:    changedBy,
+		ChangedAt:    time.Now(),
+	})
+
+	if h.limit > 0 && len(revisions) > h.limit {
+		revisions = revisions[len(revisions)-h.limit:]
+	}
+
+	return h.save(resourceType, resourceID, revisions)
+}
+
+// List 获取资源的全部修订，按修订号升序
+func (h *History) List(resourceType, resourceID string) ([]models.Revision, error) {
+	path, err := h.path(resourceType, resourceID)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []models.Revision{}, nil
+		}
+		return nil, err
+	}
+
+	var revisions []models.Revision
+	if err := json.Unmarshal(data, &revisions); err != nil {
+		return nil, err
+	}
+
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Revision < revisions[j].Revision
+	})
+
+	return revisions, nil
+}
+
+// Detail 获取单个修订及其字段差异。差异为该修订内容与紧随其后的状态
+// （下一个修订的内容，或最新修订对应的 current）之间的变化
+func (h *History) Detail(resourceType, resourceID string, revision int, current interface{}) (models.RevisionDetail, error) {
+	revisions, err := h.List(resourceType, resourceID)
+	if err != nil {
+		return models.RevisionDetail{}, err
+	}
+
+	for i, rev := range revisions {
+		if rev.Revision != revision {
+			continue
+		}
+
+		var after interface{} = current
+		if i+1 < len(revisions) {
+			after = revisions[i+1].Data
+		}
+
+		changes, err := DiffFields(rev.Data, after)
+		if err != nil {
+			return models.RevisionDetail{}, err
+		}
+
+		return models.RevisionDetail{Revision: rev, Changes: changes}, nil
+	}
+
+	return models.RevisionDetail{}, ErrRevisionNotFound
+}
+
+// Get 获取单个修订
+func (h *History) Get(resourceType, resourceID string, revision int) (models.Revision, error) {
+	revisions, err := h.List(resourceType, resourceID)
+	if err != nil {
+		return models.Revision{}, err
+	}
+
+	for _, rev := range revisions {
+		if rev.Revision == revision {
+			return rev, nil
+		}
+	}
+	return models.Revision{}, ErrRevisionNotFound
+}
+
+func (h *History) save(resourceType, resourceID string, revisions []models.Revision) error {
+	path, err := h.path(resourceType, resourceID)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(revisions, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(path, data, 0644)
+}
+
+// path 返回资源修订文件路径，拒绝未知类型与包含路径分隔符的ID
+func (h *History) path(resourceType, resourceID string) (string, error) {
+	switch resourceType {
+	case HistoryBookmark, HistoryCategory, HistorySettings:
+	default:
+		return "", fmt.Errorf("未知的资源类型: %s", resourceType)
+	}
+
+	if resourceID == "" || resourceID != filepath.Base(resourceID) || strings.HasPrefix(resourceID, ".") {
+		return "", ErrRevisionNotFound
+	}
+
+	return filepath.Join(h.dir, resourceType, resourceID+".json"), nil
+}
+
+// DiffFields 比较两个记录的 JSON 字段，返回按字段名排序的差异
+func DiffFields(before, after interface{}) ([]models.FieldChange, error) {
+	oldFields, err := toFields(before)
+	if err != nil {
+		return nil, err
+	}
+	newFields, err := toFields(after)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for name := range oldFields {
+		names[name] = true
+	}
+	for name := range newFields {
+		names[name] = true
+	}
+
+	changes := []models.FieldChange{}
+	for name := range names {
+		if diffIgnoredFields[name] {
+			continue
+		}
+		if !reflect.DeepEqual(oldFields[name], newFields[name]) {
+			changes = append(changes, models.FieldChange{
+				Field: name,
+				Old:   oldFields[name],
+				New:   newFields[name],
+			})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Field < changes[j].Field
+	})
+
+	return changes, nil
+}
+
+// toFields 将记录转换为字段映射
+func toFields(value interface{}) (map[string]interface{}, error) {
+	var data []byte
+	switch v := value.(type) {
+	case json.RawMessage:
+		data = v
+	case []byte:
+		data = v
+	default:
+		var err error
+		if data, err = json.Marshal(value); err != nil {
+			return nil, err
+		}
+	}
+
+	fields := make(map[string]interface{})
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	return fields, nil
+}
@@ -0,0 +1,216 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"navdesk/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// allCategoryID “全部”分类，书签归属该分类时不要求存在对应的分类记录
+	allCategoryID = "all"
+	// faviconUploadDir 网站图标目录，由设置页面使用，不属于任何书签或分类
+	faviconUploadDir = "favicon"
+)
+
+// CheckIntegrity 检查分类、书签与 uploads 目录之间的一致性。
+// repair 为 true 时重新生成重复的ID、将孤立书签移至“全部”分类并删除未被引用的上传文件，
+// 其余问题只报告不修复。修复时调用方需持有写锁
+func CheckIntegrity(store Store, repair bool) (models.IntegrityReport, error) {
+	report := models.IntegrityReport{
+		Repair:    repair,
+		Issues:    []models.IntegrityIssue{},
+		CheckedAt: time.Now(),
+	}
+
+	categories, err := store.GetCategories()
+	if err != nil {
+		return report, fmt.Errorf("读取分类失败: %v", err)
+	}
+	bookmarks, err := store.GetBookmarks()
+	if err != nil {
+		return report, fmt.Errorf("读取书签失败: %v", err)
+	}
+
+	categoriesChanged := false
+	bookmarksChanged := false
+	now := time.Now()
+
+	// 重复ID：保留第一条记录，其余重新生成
+	seenCategories := make(map[string]bool)
+	for i := range categories {
+		id := categories[i].ID
+		if !seenCategories[id] {
+			seenCategories[id] = true
+			continue
+		}
+		issue := models.IntegrityIssue{
+			Type:       models.IssueDuplicateID,
+			ResourceID: id,
+			Message:    fmt.Sprintf("分类「%s」的ID与其他分类重复", categories[i].Name),
+		}
+		if repair {
+			categories[i].ID = generateID("cat")
+			categories[i].Version++
+			categories[i].UpdatedAt = now
+			issue.Message += "，已重新生成为 " + categories[i].ID
+			issue.Repaired = true
+			categoriesChanged = true
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	seenBookmarks := make(map[string]bool)
+	for i := range bookmarks {
+		id := bookmarks[i].ID
+		if !seenBookmarks[id] {
+			seenBookmarks[id] = true
+			continue
+		}
+		issue := models.IntegrityIssue{
+			Type:       models.IssueDuplicateID,
+			ResourceID: id,
+			Message:    fmt.Sprintf("书签「%s」的ID与其他书签重复", bookmarks[i].Name),
+		}
+		if repair {
+			bookmarks[i].ID = generateID("bookmark")
+			bookmarks[i].Version++
+			bookmarks[i].UpdatedAt = now
+			issue.Message += "，已重新生成为 " + bookmarks[i].ID
+			issue.Repaired = true
+			bookmarksChanged = true
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	// 多个分类共用上传目录
+	uploadDirs := make(map[string]string)
+	for _, category := range categories {
+		if owner, ok := uploadDirs[category.UploadDir]; ok {
+			report.Issues = append(report.Issues, models.IntegrityIssue{
+				Type:       models.IssueSharedUploadDir,
+				ResourceID: category.ID,
+				Path:       category.UploadDir,
+				Message:    fmt.Sprintf("分类「%s」与「%s」共用上传目录 %s", category.Name, owner, category.UploadDir),
+			})
+			continue
+		}
+		uploadDirs[category.UploadDir] = category.Name
+	}
+
+	// 所属分类不存在的书签
+	categoryIDs := make(map[string]bool)
+	for _, category := range categories {
+		categoryIDs[category.ID] = true
+	}
+	for i := range bookmarks {
+		if bookmarks[i].Category == allCategoryID || categoryIDs[bookmarks[i].Category] {
+			continue
+		}
+		issue := models.IntegrityIssue{
+			Type:       models.IssueOrphanBookmark,
+			ResourceID: bookmarks[i].ID,
+			Message:    fmt.Sprintf("书签「%s」所属的分类 %s 不存在", bookmarks[i].Name, bookmarks[i].Category),
+		}
+		if repair {
+			bookmarks[i].Category = allCategoryID
+			bookmarks[i].Version++
+			bookmarks[i].UpdatedAt = now
+			issue.Message += "，已移至“全部”分类"
+			issue.Repaired = true
+			bookmarksChanged = true
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	// 图标文件缺失，同时收集被引用的上传文件
+	uploadsPath := store.GetUploadsPath()
+	referenced := make(map[string]bool)
+	checkIcon := func(resourceID, name, icon string) {
+		rel, ok := uploadRelPath(icon)
+		if !ok {
+			return
+		}
+		referenced[rel] = true
+		if _, err := os.Stat(filepath.Join(uploadsPath, rel)); os.IsNotExist(err) {
+			report.Issues = append(report.Issues, models.IntegrityIssue{
+				Type:       models.IssueMissingIcon,
+				ResourceID: resourceID,
+				Path:       filepath.ToSlash(rel),
+				Message:    fmt.Sprintf("「%s」的图标文件不存在: %s", name, icon),
+			})
+		}
+	}
+	for _, category := range categories {
+		checkIcon(category.ID, category.Name, category.Icon)
+	}
+	for _, bookmark := range bookmarks {
+		checkIcon(bookmark.ID, bookmark.Name, bookmark.Icon)
+	}
+
+	if categoriesChanged {
+		if err := store.SaveCategories(categories); err != nil {
+			return report, fmt.Errorf("保存分类失败: %v", err)
+		}
+	}
+	if bookmarksChanged {
+		if err := store.SaveBookmarks(bookmarks); err != nil {
+			return report, fmt.Errorf("保存书签失败: %v", err)
+		}
+	}
+
+	// 未被任何书签或分类引用的上传文件
+	err = filepath.Walk(uploadsPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(uploadsPath, path)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			if rel == faviconUploadDir {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !info.Mode().IsRegular() || referenced[rel] {
+			return nil
+		}
+
+		issue := models.IntegrityIssue{
+			Type:    models.IssueUnreferencedFile,
+			Path:    filepath.ToSlash(rel),
+			Message: "上传文件未被任何书签或分类引用: " + filepath.ToSlash(rel),
+		}
+		if repair {
+			if err := os.Remove(path); err != nil {
+				issue.Message += fmt.Sprintf("（删除失败: %v）", err)
+			} else {
+				issue.Message += "，已删除"
+				issue.Repaired = true
+			}
+		}
+		report.Issues = append(report.Issues, issue)
+		return nil
+	})
+	if err != nil {
+		return report, fmt.Errorf("扫描上传目录失败: %v", err)
+	}
+
+	return report, nil
+}
+
+// generateID 生成与新建分类、书签相同格式的ID
+func generateID(prefix string) string {
+	return fmt.Sprintf("%s_%d_%s", prefix, time.Now().UnixNano(), strings.ReplaceAll(uuid.New().String()[:8], "-", ""))
+}
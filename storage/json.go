@@ -0,0 +1,375 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"navdesk/models"
+)
+
+// JSONStore 基于 JSON 文件的存储实现，解析后的数据缓存在内存中
+type JSONStore struct {
+	*writeLock
+	dataPath string
+
+	cacheMu   sync.RWMutex
+	cache     map[string]*cacheEntry
+	stopWatch chan struct{}
+	stopOnce  sync.Once
+}
+
+// NewJSONStore 创建 JSON 文件存储实例
+func NewJSONStore(dataPath string) *JSONStore {
+	return &JSONStore{
+		writeLock: newWriteLock(dataPath),
+		dataPath:  dataPath,
+		cache:     make(map[string]*cacheEntry),
+	}
+}
+
+// 确保目录存在（保留此函数，因为上传功能仍需要）
+func (s *JSONStore) ensureDirectoryExists(dir string) error {
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		return os.MkdirAll(dir, 0755)
+	}
+	return nil
+}
+
+// GetUsers 获取用户数据
+func (s *JSONStore) GetUsers() (map[string]models.User, error) {
+	value, err := s.cached(usersFile)
+	if err != nil {
+		return nil, err
+	}
+
+	users := make(map[string]models.User)
+	for key, user := range value.(usersDoc).users {
+		users[key] = cloneUser(user)
+	}
+	return users, nil
+}
+
+// SaveUsers 保存用户数据，users.json 中的 secretKey 保持不变
+func (s *JSONStore) SaveUsers(users map[string]models.User) error {
+	secretKey, err := s.GetSecretKey()
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	doc := make(map[string]interface{}, len(users)+1)
+	if secretKey != "" {
+		doc["secretKey"] = secretKey
+	}
+	saved := make(map[string]models.User, len(users))
+	for key, user := range users {
+		doc[key] = user
+		saved[key] = cloneUser(user)
+	}
+
+	data, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(filepath.Join(s.dataPath, usersFile), data, dataFileMode(usersFile)); err != nil {
+		s.invalidate(usersFile)
+		return err
+	}
+	s.remember(usersFile, usersDoc{users: saved, secretKey: secretKey})
+	return nil
+}
+
+// GetSecretKey 获取会话密钥
+func (s *JSONStore) GetSecretKey() (string, error) {
+	value, err := s.cached(usersFile)
+	if err != nil {
+		return "", err
+	}
+
+	return value.(usersDoc).secretKey, nil
+}
+
+// GetCategories 获取分类数据，返回缓存的副本
+func (s *JSONStore) GetCategories() ([]models.Category, error) {
+	value, err := s.cached(categoriesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]models.Category(nil), value.([]models.Category)...), nil
+}
+
+// SaveCategories 保存分类数据
+func (s *JSONStore) SaveCategories(categories []models.Category) error {
+	categoriesPath := filepath.Join(s.dataPath, categoriesFile)
+	data, err := json.MarshalIndent(categories, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(categoriesPath, data, 0644); err != nil {
+		s.invalidate(categoriesFile)
+		return err
+	}
+	s.remember(categoriesFile, append([]models.Category(nil), categories...))
+	return nil
+}
+
+// PutCategory 新增或更新单个分类
+func (s *JSONStore) PutCategory(category models.Category) error {
+	categories, err := s.GetCategories()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range categories {
+		if categories[i].ID == category.ID {
+			categories[i] = category
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		categories = append(categories, category)
+	}
+
+	return s.SaveCategories(categories)
+}
+
+// DeleteCategory 删除单个分类记录
+func (s *JSONStore) DeleteCategory(id string) error {
+	categories, err := s.GetCategories()
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]models.Category, 0, len(categories))
+	for _, category := range categories {
+		if category.ID != id {
+			remaining = append(remaining, category)
+		}
+	}
+
+	return s.SaveCategories(remaining)
+}
+
+// GetBookmarks 获取书签数据，返回缓存的副本
+func (s *JSONStore) GetBookmarks() ([]models.Bookmark, error) {
+	value, err := s.cached(bookmarksFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]models.Bookmark(nil), value.([]models.Bookmark)...), nil
+}
+
+// SaveBookmarks 保存书签数据
+func (s *JSONStore) SaveBookmarks(bookmarks []models.Bookmark) error {
+	bookmarksPath := filepath.Join(s.dataPath, bookmarksFile)
+	data, err := json.MarshalIndent(bookmarks, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(bookmarksPath, data, 0644); err != nil {
+		s.invalidate(bookmarksFile)
+		return err
+	}
+	s.remember(bookmarksFile, append([]models.Bookmark(nil), bookmarks...))
+	return nil
+}
+
+// PutBookmark 新增或更新单个书签
+func (s *JSONStore) PutBookmark(bookmark models.Bookmark) error {
+	bookmarks, err := s.GetBookmarks()
+	if err != nil {
+		return err
+	}
+
+	replaced := false
+	for i := range bookmarks {
+		if bookmarks[i].ID == bookmark.ID {
+			bookmarks[i] = bookmark
+			replaced = true
+			break
+		}
+	}
+	if !replaced {
+		bookmarks = append(bookmarks, bookmark)
+	}
+
+	return s.SaveBookmarks(bookmarks)
+}
+
+// DeleteBookmark 删除单个书签记录
+func (s *JSONStore) DeleteBookmark(id string) error {
+	bookmarks, err := s.GetBookmarks()
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]models.Bookmark, 0, len(bookmarks))
+	for _, bookmark := range bookmarks {
+		if bookmark.ID != id {
+			remaining = append(remaining, bookmark)
+		}
+	}
+
+	return s.SaveBookmarks(remaining)
+}
+
+// GetSettings 获取设置数据
+func (s *JSONStore) GetSettings() (models.Settings, error) {
+	value, err := s.cached(settingsFile)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return models.Settings{}, err
+		}
+		// 如果文件不存在，返回默认设置
+		settings := defaultSettings()
+		settings.UpdatedAt = time.Now()
+		return settings, nil
+	}
+
+	return value.(models.Settings), nil
+}
+
+// SaveSettings 保存设置数据
+func (s *JSONStore) SaveSettings(settings models.Settings) error {
+	settingsPath := filepath.Join(s.dataPath, settingsFile)
+	settings.UpdatedAt = time.Now()
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	if err := writeFileAtomic(settingsPath, data, 0644); err != nil {
+		s.invalidate(settingsFile)
+		return err
+	}
+	s.remember(settingsFile, settings)
+	return nil
+}
+
+// GetDataPath 获取数据目录路径
+func (s *JSONStore) GetDataPath() string {
+	return s.dataPath
+}
+
+// GetUploadsPath 获取上传目录路径
+func (s *JSONStore) GetUploadsPath() string {
+	return filepath.Join(s.dataPath, uploadsDir)
+}
+
+// Close 停止文件变更检测
+func (s *JSONStore) Close() error {
+	s.stopOnce.Do(func() {
+		if s.stopWatch != nil {
+			close(s.stopWatch)
+		}
+	})
+	return nil
+}
+
+// cloneUser 复制用户记录，切片字段不与缓存共享
+func cloneUser(user models.User) models.User {
+	if user.RecoveryCodes != nil {
+		user.RecoveryCodes = append([]string(nil), user.RecoveryCodes...)
+	}
+	return user
+}
+
+// parseUsers 解析 users.json 内容，跳过 secretKey 字段
+func parseUsers(data []byte) (map[string]models.User, error) {
+	var usersData map[string]interface{}
+	if err := json.Unmarshal(data, &usersData); err != nil {
+		return nil, err
+	}
+
+	users := make(map[string]models.User)
+	for key, value := range usersData {
+		if key == "secretKey" {
+			continue // 跳过secretKey字段
+		}
+		userBytes, _ := json.Marshal(value)
+		var user models.User
+		if err := json.Unmarshal(userBytes, &user); err == nil {
+			users[key] = user
+		}
+	}
+
+	return users, nil
+}
+
+// parseSecretKey 从 users.json 内容中读取 secretKey
+func parseSecretKey(data []byte) (string, error) {
+	var usersData map[string]interface{}
+	if err := json.Unmarshal(data, &usersData); err != nil {
+		return "", err
+	}
+
+	if secretKey, exists := usersData["secretKey"]; exists {
+		if keyStr, ok := secretKey.(string); ok {
+			return keyStr, nil
+		}
+	}
+
+	return "", nil // 如果没有找到secretKey，返回空字符串
+}
+
+// ExportData 将数据文件复制到 dir，用于生成快照
+func (s *JSONStore) ExportData(dir string) error {
+	for _, name := range dataFiles {
+		src := filepath.Join(s.dataPath, name)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := copyFile(src, filepath.Join(dir, name), dataFileMode(name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ImportData 用 dir 中的数据文件替换当前数据文件。
+// 所有文件先全部校验通过再写入，写入后按需迁移到当前数据版本
+func (s *JSONStore) ImportData(dir string) error {
+	contents := make(map[string][]byte)
+	for _, name := range dataFiles {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return err
+		}
+		if parse, ok := jsonParsers[name]; ok {
+			if _, err := parse(data); err != nil {
+				return fmt.Errorf("%s 格式错误: %v", name, err)
+			}
+		}
+		contents[name] = data
+	}
+
+	if len(contents) == 0 {
+		return fmt.Errorf("未找到可导入的数据文件")
+	}
+
+	for _, name := range dataFiles {
+		data, ok := contents[name]
+		if !ok {
+			continue
+		}
+		if err := writeFileAtomic(filepath.Join(s.dataPath, name), data, dataFileMode(name)); err != nil {
+			return err
+		}
+		s.invalidate(name)
+	}
+
+	return migrate(s)
+}
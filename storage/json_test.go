@@ -0,0 +1,121 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"navdesk/models"
+)
+
+func TestJSONStoreWatchClose(t *testing.T) {
+	store := NewJSONStore(t.TempDir())
+	store.Watch(time.Millisecond)
+	time.Sleep(5 * time.Millisecond)
+
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+	// 重复关闭不应 panic
+	if err := store.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestJSONStoreGetUsersDoesNotShareRecoveryCodes(t *testing.T) {
+	store := NewJSONStore(t.TempDir())
+	users := map[string]models.User{
+		"admin": {Username: "admin", Role: models.RoleAdmin, RecoveryCodes: []string{"a", "b"}},
+	}
+	if err := store.SaveUsers(users); err != nil {
+		t.Fatal(err)
+	}
+	// 调用方在保存后修改自己的切片，不应影响缓存
+	users["admin"].RecoveryCodes[0] = "changed-by-caller"
+
+	loaded, err := store.GetUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	loaded["admin"].RecoveryCodes[1] = "changed-by-reader"
+
+	again, err := store.GetUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := again["admin"].RecoveryCodes; got[0] != "a" || got[1] != "b" {
+		t.Fatalf("缓存中的恢复码被修改: %v", got)
+	}
+}
+
+func TestJSONStoreUsersFileMode(t *testing.T) {
+	store := NewJSONStore(t.TempDir())
+	if err := store.SaveUsers(map[string]models.User{"admin": {Username: "admin", Role: models.RoleAdmin}}); err != nil {
+		t.Fatal(err)
+	}
+	exportDir := t.TempDir()
+	if err := store.ExportData(exportDir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{
+		filepath.Join(store.GetDataPath(), usersFile),
+		filepath.Join(exportDir, usersFile),
+	} {
+		info, err := os.Stat(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if perm := info.Mode().Perm(); perm != 0600 {
+			t.Errorf("%s 权限为 %o，want 600", path, perm)
+		}
+	}
+}
+
+func TestJSONStoreSeesWritesFromOtherProcess(t *testing.T) {
+	dir := t.TempDir()
+	// 两个实例模拟共享同一数据目录的两个进程
+	first := NewJSONStore(dir)
+	second := NewJSONStore(dir)
+
+	if err := first.SaveCategories([]models.Category{{ID: "a", Name: "A"}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := second.GetCategories(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 两个实例交替在写锁内读取-修改-保存，任何一方都不能覆盖另一方的修改
+	for _, step := range []struct {
+		store *JSONStore
+		id    string
+	}{
+		{first, "b"},
+		{second, "c"},
+		{first, "d"},
+	} {
+		if err := step.store.Lock(); err != nil {
+			t.Fatal(err)
+		}
+		err := step.store.PutCategory(models.Category{ID: step.id, Name: step.id})
+		step.store.Unlock()
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, store := range []*JSONStore{first, second} {
+		categories, err := store.GetCategories()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var ids []string
+		for _, category := range categories {
+			ids = append(ids, category.ID)
+		}
+		if len(ids) != 4 {
+			t.Fatalf("分类为 %v，want a b c d", ids)
+		}
+	}
+}
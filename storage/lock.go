@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// writeLock 写操作锁：进程内互斥锁 + 数据目录下的咨询文件锁。
+// 文件锁保证共享同一数据卷的多个 navdesk 进程不会交错写入
+type writeLock struct {
+	mu   sync.Mutex
+	path string
+	file *os.File
+}
+
+func newWriteLock(dataPath string) *writeLock {
+	return newFileLock(filepath.Join(dataPath, lockFile))
+}
+
+// newFileLock 创建使用指定锁文件的写锁，用于不经过 Store 读写的独立数据文件
+func newFileLock(path string) *writeLock {
+	return &writeLock{
+		path: path,
+	}
+}
+
+// Lock 获取写锁，阻塞直到进程内和跨进程的锁都已持有
+func (l *writeLock) Lock() error {
+	l.mu.Lock()
+
+	file, err := os.OpenFile(l.path, os.O_CREATE|os.O_RDWR, 0644)
+	if err != nil {
+		l.mu.Unlock()
+		return err
+	}
+	if err := lockFileExclusive(file); err != nil {
+		file.Close()
+		l.mu.Unlock()
+		return err
+	}
+
+	l.file = file
+	return nil
+}
+
+// Unlock 释放写锁
+func (l *writeLock) Unlock() {
+	if l.file != nil {
+		unlockFile(l.file)
+		l.file.Close()
+		l.file = nil
+	}
+	l.mu.Unlock()
+}
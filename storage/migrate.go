@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+)
+
+// SchemaVersion 当前程序使用的数据结构版本。
+// 修改 models 中持久化的结构时，将其加一并在 migrations 末尾追加对应的迁移
+const SchemaVersion = 2
+
+// schemaMeta 数据目录元信息（meta.json）
+type schemaMeta struct {
+	SchemaVersion int       `json:"schemaVersion"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// rawRecord 未绑定模型的原始 JSON 对象
+type rawRecord = map[string]interface{}
+
+// dataset 迁移时使用的原始数据。记录保持为通用 JSON 结构，
+// 迁移函数因此不依赖当前版本的模型定义
+type dataset struct {
+	Users      rawRecord   // users.json 原始对象（包含 secretKey）
+	Categories []rawRecord // 分类列表，nil 表示不存在
+	Bookmarks  []rawRecord // 书签列表，nil 表示不存在
+	Settings   rawRecord   // 设置，nil 表示不存在
+}
+
+// migration 单个版本的迁移步骤，将数据从 Version-1 升级到 Version
+type migration struct {
+	Version     int
+	Description string
+	Apply       func(d *dataset) error
+}
+
+// migrations 按版本顺序排列的全部迁移
+var migrations = []migration{
+	{
+		Version:     1,
+		Description: "引入 meta.json，补全书签 tags 与分类 uploadDir 的缺省值",
+		Apply: func(d *dataset) error {
+			for _, bookmark := range d.Bookmarks {
+				if tags, ok := bookmark["tags"].([]interface{}); !ok || tags == nil {
+					bookmark["tags"] = []interface{}{}
+				}
+			}
+			for _, category := range d.Categories {
+				if dir, _ := category["uploadDir"].(string); dir == "" {
+					category["uploadDir"] = category["id"]
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version:     2,
+		Description: "为分类、书签与设置增加 version 字段，用于并发修改检测",
+		Apply: func(d *dataset) error {
+			records := append(append([]rawRecord{}, d.Categories...), d.Bookmarks...)
+			if d.Settings != nil {
+				records = append(records, d.Settings)
+			}
+			for _, record := range records {
+				if rawInt(record, "version") < 1 {
+					record["version"] = 1
+				}
+			}
+			return nil
+		},
+	},
+}
+
+// migrationTarget 支持迁移的存储实现
+type migrationTarget interface {
+	// schemaVersion 读取已记录的数据版本，从未记录时返回 0
+	schemaVersion() (int, error)
+	// backupData 迁移前备份数据，返回备份位置
+	backupData(fromVersion int) (string, error)
+	loadDataset() (*dataset, error)
+	// saveDataset 写回迁移后的数据并记录新版本
+	saveDataset(d *dataset, version int) error
+}
+
+// migrate 检查数据版本，必要时先备份再依次执行迁移
+func migrate(target migrationTarget) error {
+	current, err := target.schemaVersion()
+	if err != nil {
+		return fmt.Errorf("读取数据版本失败: %v", err)
+	}
+
+	if current > SchemaVersion {
+		return fmt.Errorf("数据版本 %d 高于当前程序支持的版本 %d，请升级 navdesk", current, SchemaVersion)
+	}
+	if current == SchemaVersion {
+		return nil
+	}
+
+	backupPath, err := target.backupData(current)
+	if err != nil {
+		return fmt.Errorf("迁移前备份数据失败: %v", err)
+	}
+	log.Printf("数据版本 %d 需要升级到 %d，已备份到: %s", current, SchemaVersion, backupPath)
+
+	data, err := target.loadDataset()
+	if err != nil {
+		return fmt.Errorf("读取待迁移数据失败: %v", err)
+	}
+
+	for _, m := range migrations {
+		if m.Version <= current {
+			continue
+		}
+		if err := m.Apply(data); err != nil {
+			return fmt.Errorf("执行迁移 v%d 失败: %v", m.Version, err)
+		}
+		log.Printf("数据迁移 v%d 完成: %s", m.Version, m.Description)
+	}
+
+	if err := target.saveDataset(data, SchemaVersion); err != nil {
+		return fmt.Errorf("保存迁移后的数据失败: %v", err)
+	}
+
+	log.Printf("数据迁移完成，当前数据版本: %d", SchemaVersion)
+	return nil
+}
+
+// decodeRaw 解析原始 JSON，数字保持原样避免精度变化
+func decodeRaw(data []byte, v interface{}) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+// rawString 读取原始记录中的字符串字段
+func rawString(record rawRecord, key string) string {
+	value, _ := record[key].(string)
+	return value
+}
+
+// rawInt 读取原始记录中的整数字段
+func rawInt(record rawRecord, key string) int {
+	switch value := record[key].(type) {
+	case json.Number:
+		n, _ := value.Int64()
+		return int(n)
+	case float64:
+		return int(value)
+	}
+	return 0
+}
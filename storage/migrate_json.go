@@ -0,0 +1,109 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// dataFiles 参与迁移与备份的数据文件
+var dataFiles = []string{usersFile, categoriesFile, bookmarksFile, settingsFile, metaFile}
+
+func (s *JSONStore) schemaVersion() (int, error) {
+	data, err := ioutil.ReadFile(filepath.Join(s.dataPath, metaFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+
+	var meta schemaMeta
+	if err := json.Unmarshal(data, &meta); err != nil {
+		return 0, err
+	}
+	return meta.SchemaVersion, nil
+}
+
+func (s *JSONStore) backupData(fromVersion int) (string, error) {
+	backupPath := filepath.Join(s.dataPath, backupsDir,
+		fmt.Sprintf("schema-v%d-%s", fromVersion, time.Now().Format("20060102-150405")))
+	if err := os.MkdirAll(backupPath, 0755); err != nil {
+		return "", err
+	}
+
+	for _, name := range dataFiles {
+		src := filepath.Join(s.dataPath, name)
+		if _, err := os.Stat(src); os.IsNotExist(err) {
+			continue
+		}
+		if err := copyFile(src, filepath.Join(backupPath, name), dataFileMode(name)); err != nil {
+			return "", err
+		}
+	}
+
+	return backupPath, nil
+}
+
+func (s *JSONStore) loadDataset() (*dataset, error) {
+	d := &dataset{}
+	targets := map[string]interface{}{
+		usersFile:      &d.Users,
+		categoriesFile: &d.Categories,
+		bookmarksFile:  &d.Bookmarks,
+		settingsFile:   &d.Settings,
+	}
+
+	for name, target := range targets {
+		data, err := ioutil.ReadFile(filepath.Join(s.dataPath, name))
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return nil, err
+		}
+		if err := decodeRaw(data, target); err != nil {
+			return nil, fmt.Errorf("%s: %v", name, err)
+		}
+	}
+
+	return d, nil
+}
+
+func (s *JSONStore) saveDataset(d *dataset, version int) error {
+	docs := map[string]interface{}{}
+	if d.Users != nil {
+		docs[usersFile] = d.Users
+	}
+	if d.Categories != nil {
+		docs[categoriesFile] = d.Categories
+	}
+	if d.Bookmarks != nil {
+		docs[bookmarksFile] = d.Bookmarks
+	}
+	if d.Settings != nil {
+		docs[settingsFile] = d.Settings
+	}
+	docs[metaFile] = schemaMeta{SchemaVersion: version, UpdatedAt: time.Now()}
+
+	// meta.json 最后写入，中途失败时下次启动会重新执行迁移
+	for _, name := range dataFiles {
+		doc, ok := docs[name]
+		if !ok {
+			continue
+		}
+		data, err := json.MarshalIndent(doc, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := writeFileAtomic(filepath.Join(s.dataPath, name), data, dataFileMode(name)); err != nil {
+			return err
+		}
+		s.invalidate(name)
+	}
+
+	return nil
+}
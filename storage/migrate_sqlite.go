@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// docMeta 数据版本信息在 documents 表中的名称
+const docMeta = "meta"
+
+func (s *SQLiteStore) schemaVersion() (int, error) {
+	var meta schemaMeta
+	if err := s.getDocument(docMeta, &meta); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, nil
+		}
+		return 0, err
+	}
+	return meta.SchemaVersion, nil
+}
+
+func (s *SQLiteStore) backupData(fromVersion int) (string, error) {
+	dir := filepath.Join(s.dataPath, backupsDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+
+	backupPath := filepath.Join(dir, fmt.Sprintf("schema-v%d-%s.db", fromVersion, time.Now().Format("20060102-150405")))
+	if _, err := s.db.Exec("VACUUM INTO ?", backupPath); err != nil {
+		return "", err
+	}
+	// 备份中包含用户表，与 users.json 一样只允许属主读写
+	if err := os.Chmod(backupPath, 0600); err != nil {
+		return "", err
+	}
+	return backupPath, nil
+}
+
+// queryRaw 读取指定表中全部记录的原始 JSON
+func (s *SQLiteStore) queryRaw(query string) ([]rawRecord, error) {
+	rows, err := s.db.Query(query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	records := []rawRecord{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var record rawRecord
+		if err := decodeRaw([]byte(data), &record); err != nil {
+			return nil, err
+		}
+		records = append(records, record)
+	}
+	return records, rows.Err()
+}
+
+func (s *SQLiteStore) loadDataset() (*dataset, error) {
+	d := &dataset{Users: rawRecord{}}
+
+	rows, err := s.db.Query("SELECT username, data FROM users")
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		var key, data string
+		if err := rows.Scan(&key, &data); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		var user rawRecord
+		if err := decodeRaw([]byte(data), &user); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		d.Users[key] = user
+	}
+	rows.Close()
+
+	var secretKey string
+	if err := s.getDocument(docSecretKey, &secretKey); err == nil {
+		d.Users["secretKey"] = secretKey
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	if d.Categories, err = s.queryRaw("SELECT data FROM categories ORDER BY rowid"); err != nil {
+		return nil, err
+	}
+	if d.Bookmarks, err = s.queryRaw("SELECT data FROM bookmarks ORDER BY rowid"); err != nil {
+		return nil, err
+	}
+
+	var settings rawRecord
+	if err := s.getDocument(docSettings, &settings); err == nil {
+		d.Settings = settings
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	return d, nil
+}
+
+func (s *SQLiteStore) saveDataset(d *dataset, version int) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"users", "categories", "bookmarks"} {
+		if _, err := tx.Exec("DELETE FROM " + table); err != nil {
+			return err
+		}
+	}
+
+	for key, value := range d.Users {
+		if key == "secretKey" {
+			if err := putDocument(tx, docSecretKey, value); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := putJSON(tx, "INSERT INTO users (username, data) VALUES (?, ?)", value, key); err != nil {
+			return err
+		}
+	}
+
+	for _, category := range d.Categories {
+		if err := putJSON(tx, "INSERT INTO categories (id, sort, data) VALUES (?, ?, ?)",
+			category, rawString(category, "id"), rawInt(category, "sort")); err != nil {
+			return err
+		}
+	}
+
+	for _, bookmark := range d.Bookmarks {
+		if err := putJSON(tx, "INSERT INTO bookmarks (id, category, sort, data) VALUES (?, ?, ?, ?)",
+			bookmark, rawString(bookmark, "id"), rawString(bookmark, "category"), rawInt(bookmark, "sort")); err != nil {
+			return err
+		}
+	}
+
+	if d.Settings != nil {
+		if err := putDocument(tx, docSettings, d.Settings); err != nil {
+			return err
+		}
+	}
+
+	if err := putDocument(tx, docMeta, schemaMeta{SchemaVersion: version, UpdatedAt: time.Now()}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
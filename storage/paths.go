@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ErrUnsafePath 路径越出了上传目录
+var ErrUnsafePath = errors.New("路径不在上传目录内")
+
+// uploadDirPattern 分类上传目录名：字母或数字开头，只含字母、数字、下划线和横线
+var uploadDirPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// ValidUploadDir 判断分类上传目录名是否合法，不允许路径分隔符、点号和系统保留的目录
+func ValidUploadDir(name string) bool {
+	return uploadDirPattern.MatchString(name) && !strings.EqualFold(name, faviconUploadDir)
+}
+
+// ResolveUploadPath 将 uploads 下以 / 分隔的相对路径解析为文件系统路径，处理器中的文件操作都应经过这里。
+// 路径为空、为绝对路径、含有越界的 ..，或经符号链接指向 uploads 以外时返回 ErrUnsafePath
+func ResolveUploadPath(store Store, rel string) (string, error) {
+	clean := cleanUploadRel(rel)
+	if clean == "" {
+		return "", ErrUnsafePath
+	}
+
+	root := store.GetUploadsPath()
+	path := filepath.Join(root, clean)
+	if !insideUploads(root, path) {
+		return "", ErrUnsafePath
+	}
+	return path, nil
+}
+
+// ResolveUploadURL 将 /uploads/... 形式的图标地址解析为文件系统路径，规则同 ResolveUploadPath
+func ResolveUploadURL(store Store, url string) (string, error) {
+	if !strings.HasPrefix(url, "/uploads/") {
+		return "", ErrUnsafePath
+	}
+	return ResolveUploadPath(store, strings.TrimPrefix(url, "/uploads/"))
+}
+
+// insideUploads 解析符号链接后确认路径仍位于 uploads 目录内。
+// 路径尚不存在时检查最近一级已存在的上级目录
+func insideUploads(root, path string) bool {
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		// uploads 目录尚未创建时，其中也不可能有符号链接
+		return os.IsNotExist(err)
+	}
+
+	existing := path
+	for {
+		real, err := filepath.EvalSymlinks(existing)
+		if err == nil {
+			rel, err := filepath.Rel(realRoot, real)
+			if err != nil {
+				return false
+			}
+			if existing == path {
+				// 路径本身不能指向 uploads 根目录
+				return cleanUploadRel(rel) != ""
+			}
+			return rel == "." || cleanUploadRel(rel) != ""
+		}
+		if !os.IsNotExist(err) {
+			return false
+		}
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			return false
+		}
+		existing = parent
+	}
+}
+
+// uploadRelPath 将 /uploads/... 形式的图标地址转换为 uploads 目录下的相对路径
+func uploadRelPath(icon string) (string, bool) {
+	if !strings.HasPrefix(icon, "/uploads/") {
+		return "", false
+	}
+	rel := cleanUploadRel(strings.TrimPrefix(icon, "/uploads/"))
+	return rel, rel != ""
+}
+
+// cleanUploadRel 规范化 uploads 下的相对路径，越界或为空时返回空字符串
+func cleanUploadRel(rel string) string {
+	rel = filepath.Clean(filepath.FromSlash(rel))
+	if rel == "." || filepath.IsAbs(rel) || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return ""
+	}
+	return rel
+}
@@ -0,0 +1,114 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestResolveUploadPathRejectsTraversal(t *testing.T) {
+	store := NewJSONStore(t.TempDir())
+
+	for _, rel := range []string{
+		"",
+		".",
+		"..",
+		"../users.json",
+		"../../etc/passwd",
+		"common/../../users.json",
+		"common/../..",
+		"/etc/passwd",
+		"/",
+	} {
+		if path, err := ResolveUploadPath(store, rel); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("ResolveUploadPath(%q) = %q, %v; want ErrUnsafePath", rel, path, err)
+		}
+	}
+}
+
+func TestResolveUploadPathAllowsUploads(t *testing.T) {
+	store := NewJSONStore(t.TempDir())
+	uploads := store.GetUploadsPath()
+
+	for rel, want := range map[string]string{
+		"common":                  filepath.Join(uploads, "common"),
+		"common/icon.png":         filepath.Join(uploads, "common", "icon.png"),
+		"common/../tool/icon.png": filepath.Join(uploads, "tool", "icon.png"),
+		"favicon/favicon.ico":     filepath.Join(uploads, "favicon", "favicon.ico"),
+	} {
+		path, err := ResolveUploadPath(store, rel)
+		if err != nil || path != want {
+			t.Errorf("ResolveUploadPath(%q) = %q, %v; want %q", rel, path, err, want)
+		}
+	}
+}
+
+func TestResolveUploadPathRejectsSymlinkEscape(t *testing.T) {
+	dataPath := t.TempDir()
+	store := NewJSONStore(dataPath)
+	uploads := store.GetUploadsPath()
+	if err := os.MkdirAll(uploads, 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(dataPath, filepath.Join(uploads, "escape")); err != nil {
+		t.Skipf("无法创建符号链接: %v", err)
+	}
+
+	for _, rel := range []string{"escape", "escape/users.json", "escape/new/icon.png"} {
+		if path, err := ResolveUploadPath(store, rel); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("ResolveUploadPath(%q) = %q, %v; want ErrUnsafePath", rel, path, err)
+		}
+	}
+}
+
+func TestResolveUploadURL(t *testing.T) {
+	store := NewJSONStore(t.TempDir())
+
+	for _, url := range []string{
+		"/uploads/../users.json",
+		"/uploads/common/../../users.json",
+		"/uploads/",
+		"/users.json",
+		"uploads/common/icon.png",
+		"https://example.com/uploads/icon.png",
+	} {
+		if path, err := ResolveUploadURL(store, url); !errors.Is(err, ErrUnsafePath) {
+			t.Errorf("ResolveUploadURL(%q) = %q, %v; want ErrUnsafePath", url, path, err)
+		}
+	}
+
+	want := filepath.Join(store.GetUploadsPath(), "common", "icon.png")
+	if path, err := ResolveUploadURL(store, "/uploads/common/icon.png"); err != nil || path != want {
+		t.Errorf("ResolveUploadURL = %q, %v; want %q", path, err, want)
+	}
+}
+
+func TestValidUploadDir(t *testing.T) {
+	for name, want := range map[string]bool{
+		"common":                true,
+		"dev-tools":             true,
+		"my_icons2":             true,
+		"":                      false,
+		".":                     false,
+		"..":                    false,
+		"../data":               false,
+		"a/b":                   false,
+		`a\b`:                   false,
+		".hidden":               false,
+		"-dash":                 false,
+		"with space":            false,
+		"dot.dir":               false,
+		"favicon":               false,
+		"FAVICON":               false,
+		"常用":                    false,
+		"a\x00b":                false,
+		strings.Repeat("a", 64): true,
+		strings.Repeat("a", 65): false,
+	} {
+		if got := ValidUploadDir(name); got != want {
+			t.Errorf("ValidUploadDir(%q) = %v; want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,259 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"navdesk/models"
+)
+
+const (
+	sessionsFile     = "sessions.json"
+	sessionsLockFile = ".sessions.lock"
+
+	// sessionTouchInterval 最近活动时间的最小更新间隔，避免每个请求都写文件
+	sessionTouchInterval = time.Minute
+)
+
+var (
+	// ErrSessionNotFound 会话不存在
+	ErrSessionNotFound = errors.New("会话不存在")
+	// ErrSessionInvalid 会话无效、已被注销或已过期
+	ErrSessionInvalid = errors.New("会话无效或已过期")
+)
+
+// storedSession sessions.json 中的会话记录
+type storedSession struct {
+	models.Session
+	Hash string `json:"hash"`
+}
+
+// Sessions 服务端登录会话，保存在 data/sessions.json 中，不随快照备份与恢复。
+// 修改会话时持有 sessions.json 专用的跨进程文件锁，一个进程注销的会话不会被其他进程更新活动时间时写回。
+// 不使用数据写锁：登录与修改用户等请求在持有数据写锁时也会创建或注销会话
+type Sessions struct {
+	path        string
+	lock        *writeLock
+	idleTimeout time.Duration
+	maxAge      time.Duration
+}
+
+// NewSessions 创建会话管理器。idleTimeout 为空闲超时，maxAge 为会话最长有效期，0 表示不限制
+func NewSessions(store Store, idleTimeout, maxAge time.Duration) *Sessions {
+	return &Sessions{
+		path:        filepath.Join(store.GetDataPath(), sessionsFile),
+		lock:        newFileLock(filepath.Join(store.GetDataPath(), sessionsLockFile)),
+		idleTimeout: idleTimeout,
+		maxAge:      maxAge,
+	}
+}
+
+// List 列出用户的会话（最近活动在前）
+func (s *Sessions) List(username string) ([]models.Session, error) {
+	records, err := s.load()
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	sessions := []models.Session{}
+	for _, record := range records {
+		if record.Username == username && !s.expired(record, now) {
+			sessions = append(sessions, record.Session)
+		}
+	}
+	sort.Slice(sessions, func(i, j int) bool {
+		return sessions[i].LastSeenAt.After(sessions[j].LastSeenAt)
+	})
+	return sessions, nil
+}
+
+// Create 创建会话，返回会话信息与会话密钥（保存在 Cookie 中）。顺带清理已过期的会话
+func (s *Sessions) Create(username, userAgent, ip string) (models.Session, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return models.Session{}, "", err
+	}
+	raw := base64.RawURLEncoding.EncodeToString(secret)
+
+	if len(userAgent) > 256 {
+		userAgent = userAgent[:256]
+	}
+	now := time.Now()
+	record := storedSession{
+		Session: models.Session{
+			ID:         generateID("ses"),
+			Username:   username,
+			UserAgent:  userAgent,
+			IP:         ip,
+			CreatedAt:  now,
+			LastSeenAt: now,
+		},
+		Hash: hashToken(raw),
+	}
+
+	err := s.update(func(records []storedSession) ([]storedSession, error) {
+		kept := records[:0]
+		for _, existing := range records {
+			if !s.expired(existing, now) {
+				kept = append(kept, existing)
+			}
+		}
+		return append(kept, record), nil
+	})
+	if err != nil {
+		return models.Session{}, "", err
+	}
+
+	return record.Session, raw, nil
+}
+
+// Get 获取会话信息
+func (s *Sessions) Get(id string) (models.Session, error) {
+	records, err := s.load()
+	if err != nil {
+		return models.Session{}, err
+	}
+	for _, record := range records {
+		if record.ID == id {
+			return record.Session, nil
+		}
+	}
+	return models.Session{}, ErrSessionNotFound
+}
+
+// Revoke 注销会话
+func (s *Sessions) Revoke(id string) error {
+	return s.update(func(records []storedSession) ([]storedSession, error) {
+		for i, record := range records {
+			if record.ID == id {
+				return append(records[:i], records[i+1:]...), nil
+			}
+		}
+		return nil, ErrSessionNotFound
+	})
+}
+
+// RevokeUser 注销用户除 exceptID 以外的全部会话，返回注销的数量
+func (s *Sessions) RevokeUser(username, exceptID string) (int, error) {
+	revoked := 0
+	err := s.update(func(records []storedSession) ([]storedSession, error) {
+		kept := records[:0]
+		for _, record := range records {
+			if record.Username != username || record.ID == exceptID {
+				kept = append(kept, record)
+			}
+		}
+		revoked = len(records) - len(kept)
+		if revoked == 0 {
+			return nil, nil
+		}
+		return kept, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return revoked, nil
+}
+
+// Authenticate 校验会话密钥，返回对应的会话并更新最近活动时间与 IP
+func (s *Sessions) Authenticate(raw, ip string) (models.Session, error) {
+	if raw == "" {
+		return models.Session{}, ErrSessionInvalid
+	}
+	hash := hashToken(raw)
+
+	records, err := s.load()
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	now := time.Now()
+	for _, record := range records {
+		if subtle.ConstantTimeCompare([]byte(record.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		if s.expired(record, now) {
+			// 清理失败不影响结果，创建会话时还会再次清理过期会话
+			s.Revoke(record.ID)
+			return models.Session{}, ErrSessionInvalid
+		}
+
+		if now.Sub(record.LastSeenAt) >= sessionTouchInterval || record.IP != ip {
+			record.LastSeenAt = now
+			record.IP = ip
+			// 最近活动时间只是参考信息，写入失败不影响认证
+			s.update(func(records []storedSession) ([]storedSession, error) {
+				for i := range records {
+					// 读取后已被其他进程注销的会话不会重新写回
+					if records[i].ID == record.ID {
+						records[i].LastSeenAt = now
+						records[i].IP = ip
+						return records, nil
+					}
+				}
+				return nil, nil
+			})
+		}
+		return record.Session, nil
+	}
+
+	return models.Session{}, ErrSessionInvalid
+}
+
+// update 持有文件锁读取、修改并保存会话，apply 返回错误或 nil 时不保存
+func (s *Sessions) update(apply func([]storedSession) ([]storedSession, error)) error {
+	if err := s.lock.Lock(); err != nil {
+		return err
+	}
+	defer s.lock.Unlock()
+
+	records, err := s.load()
+	if err != nil {
+		return err
+	}
+	records, err = apply(records)
+	if err != nil || records == nil {
+		return err
+	}
+	return s.save(records)
+}
+
+// expired 判断会话是否已空闲超时或超过最长有效期
+func (s *Sessions) expired(record storedSession, now time.Time) bool {
+	if s.idleTimeout > 0 && now.Sub(record.LastSeenAt) > s.idleTimeout {
+		return true
+	}
+	return s.maxAge > 0 && now.Sub(record.CreatedAt) > s.maxAge
+}
+
+func (s *Sessions) load() ([]storedSession, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []storedSession{}, nil
+		}
+		return nil, err
+	}
+
+	var records []storedSession
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (s *Sessions) save(records []storedSession) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(s.path, data, 0600)
+}
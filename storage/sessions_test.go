@@ -0,0 +1,58 @@
+package storage
+
+import (
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSessionsSharedAcrossProcesses(t *testing.T) {
+	store := NewJSONStore(t.TempDir())
+	// 两个实例模拟共享同一数据目录的两个进程
+	first, second := NewSessions(store, time.Hour, 0), NewSessions(store, time.Hour, 0)
+
+	var raws []string
+	for i := 0; i < 10; i++ {
+		_, raw, err := first.Create("admin", "test", "192.0.2.1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		raws = append(raws, raw)
+	}
+
+	// 一个进程注销全部会话的同时，另一个进程从新的 IP 访问并更新会话；
+	// 同时两个进程都在创建新会话
+	var wg sync.WaitGroup
+	for i, raw := range raws {
+		wg.Add(3)
+		go func(raw string) {
+			defer wg.Done()
+			second.Authenticate(raw, "192.0.2.2")
+		}(raw)
+		go func() {
+			defer wg.Done()
+			if _, err := first.RevokeUser("admin", ""); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func(sessions *Sessions) {
+			defer wg.Done()
+			if _, _, err := sessions.Create("editor", "test", "192.0.2.3"); err != nil {
+				t.Error(err)
+			}
+		}([]*Sessions{first, second}[i%2])
+	}
+	wg.Wait()
+
+	if sessions, err := first.List("admin"); err != nil || len(sessions) != 0 {
+		t.Fatalf("已注销的会话 %d 个重新出现: %v", len(sessions), err)
+	}
+	for _, raw := range raws {
+		if _, err := second.Authenticate(raw, "192.0.2.2"); err != ErrSessionInvalid {
+			t.Fatalf("已注销的会话仍可使用: %v", err)
+		}
+	}
+	if sessions, err := second.List("editor"); err != nil || len(sessions) != len(raws) {
+		t.Fatalf("创建了 %d 个会话，保存了 %d 个: %v", len(raws), len(sessions), err)
+	}
+}
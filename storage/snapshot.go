@@ -0,0 +1,403 @@
+package storage
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"navdesk/models"
+)
+
+const (
+	snapshotExt        = ".tar.gz"
+	snapshotTimeLayout = "20060102-150405.000"
+)
+
+// 快照原因
+const (
+	SnapshotScheduled      = "scheduled"
+	SnapshotManual         = "manual"
+	SnapshotDeleteCategory = "delete-category"
+	SnapshotPreRestore     = "pre-restore"
+	SnapshotPreRepair      = "pre-repair"
+)
+
+// snapshotIDPattern 快照ID格式：时间戳-原因
+var snapshotIDPattern = regexp.MustCompile(`^(\d{8}-\d{6}\.\d{3})-([a-z-]+)$`)
+
+// SnapshotManager 数据快照管理。快照为 tar.gz 归档，包含数据文件
+// （JSON 文件或 SQLite 数据库）以及 uploads 目录
+type SnapshotManager struct {
+	store     Store
+	dir       string
+	retention int
+	stop      chan struct{}
+}
+
+// NewSnapshotManager 创建快照管理器，retention 为每种原因各自保留的快照数量（0 表示不限制）
+func NewSnapshotManager(store Store, retention int) *SnapshotManager {
+	return &SnapshotManager{
+		store:     store,
+		dir:       filepath.Join(store.GetDataPath(), snapshotsDir),
+		retention: retention,
+	}
+}
+
+// Schedule 按固定间隔自动创建快照
+func (m *SnapshotManager) Schedule(interval time.Duration) {
+	m.stop = make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := m.store.Lock(); err != nil {
+					log.Printf("定时快照获取写锁失败: %v", err)
+					continue
+				}
+				snapshot, err := m.Create(SnapshotScheduled)
+				m.store.Unlock()
+				if err != nil {
+					log.Printf("定时快照创建失败: %v", err)
+				} else {
+					log.Printf("定时快照创建成功: %s", snapshot.ID)
+				}
+			case <-m.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定时快照
+func (m *SnapshotManager) Stop() {
+	if m.stop != nil {
+		close(m.stop)
+		m.stop = nil
+	}
+}
+
+// Create 创建快照并清理同一原因下超出保留数量的旧快照，调用方需持有写锁
+func (m *SnapshotManager) Create(reason string) (models.Snapshot, error) {
+	if err := os.MkdirAll(m.dir, 0755); err != nil {
+		return models.Snapshot{}, err
+	}
+
+	createdAt := time.Now()
+	id := createdAt.Format(snapshotTimeLayout) + "-" + reason
+	if !snapshotIDPattern.MatchString(id) {
+		return models.Snapshot{}, fmt.Errorf("无效的快照原因: %s", reason)
+	}
+
+	// 先导出到临时目录，保证归档内容来自同一时刻
+	staging, err := os.MkdirTemp(m.dir, ".staging-")
+	if err != nil {
+		return models.Snapshot{}, err
+	}
+	defer os.RemoveAll(staging)
+
+	if err := m.store.ExportData(staging); err != nil {
+		return models.Snapshot{}, fmt.Errorf("导出数据失败: %v", err)
+	}
+
+	archivePath := filepath.Join(m.dir, id+snapshotExt)
+	tmpPath := archivePath + ".tmp"
+	if err := writeSnapshotArchive(tmpPath, staging, m.store.GetUploadsPath()); err != nil {
+		os.Remove(tmpPath)
+		return models.Snapshot{}, err
+	}
+	if err := os.Rename(tmpPath, archivePath); err != nil {
+		os.Remove(tmpPath)
+		return models.Snapshot{}, err
+	}
+
+	info, err := os.Stat(archivePath)
+	if err != nil {
+		return models.Snapshot{}, err
+	}
+
+	m.prune()
+
+	return models.Snapshot{
+		ID:        id,
+		Reason:    reason,
+		Size:      info.Size(),
+		CreatedAt: createdAt,
+	}, nil
+}
+
+// List 列出全部快照，按时间倒序
+func (m *SnapshotManager) List() ([]models.Snapshot, error) {
+	entries, err := os.ReadDir(m.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []models.Snapshot{}, nil
+		}
+		return nil, err
+	}
+
+	snapshots := []models.Snapshot{}
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasSuffix(entry.Name(), snapshotExt) {
+			continue
+		}
+		id := strings.TrimSuffix(entry.Name(), snapshotExt)
+		match := snapshotIDPattern.FindStringSubmatch(id)
+		if match == nil {
+			continue
+		}
+		createdAt, err := time.ParseInLocation(snapshotTimeLayout, match[1], time.Local)
+		if err != nil {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, models.Snapshot{
+			ID:        id,
+			Reason:    match[2],
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+		})
+	}
+
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].ID > snapshots[j].ID
+	})
+
+	return snapshots, nil
+}
+
+// Path 返回快照归档文件路径，ID 非法或快照不存在时返回错误
+func (m *SnapshotManager) Path(id string) (string, error) {
+	if !snapshotIDPattern.MatchString(id) {
+		return "", os.ErrNotExist
+	}
+	path := filepath.Join(m.dir, id+snapshotExt)
+	if _, err := os.Stat(path); err != nil {
+		return "", err
+	}
+	return path, nil
+}
+
+// Restore 从快照恢复数据与 uploads 目录，调用方需持有写锁。
+// 恢复前会先创建一个 pre-restore 快照；数据与 uploads 目录的替换在同一个事务中完成，
+// 任一步骤失败（或进程中途退出）都会恢复原来的数据与 uploads 目录
+func (m *SnapshotManager) Restore(id string) error {
+	archivePath, err := m.Path(id)
+	if err != nil {
+		return err
+	}
+
+	if _, err := m.Create(SnapshotPreRestore); err != nil {
+		return fmt.Errorf("创建恢复前快照失败: %v", err)
+	}
+
+	staging, err := os.MkdirTemp(m.dir, ".restore-")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	if err := extractSnapshotArchive(archivePath, staging); err != nil {
+		return fmt.Errorf("解压快照失败: %v", err)
+	}
+
+	stagedUploads := filepath.Join(staging, uploadsDir)
+	if err := os.MkdirAll(stagedUploads, 0755); err != nil {
+		return err
+	}
+
+	tx, err := BeginTx(m.store, TxAllData)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// 用快照中的 uploads 整体替换当前目录，旧目录移入事务日志，提交时才删除
+	uploadsPath := m.store.GetUploadsPath()
+	if err := tx.Remove(uploadsPath); err != nil {
+		return err
+	}
+	if err := tx.Rename(stagedUploads, uploadsPath); err != nil {
+		return err
+	}
+
+	if err := m.store.ImportData(staging); err != nil {
+		return fmt.Errorf("导入数据失败: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// prune 按原因分别删除超出保留数量的旧快照，删除前快照不会挤掉定时快照
+func (m *SnapshotManager) prune() {
+	if m.retention <= 0 {
+		return
+	}
+
+	snapshots, err := m.List()
+	if err != nil {
+		log.Printf("清理旧快照失败: %v", err)
+		return
+	}
+
+	// List 按时间从新到旧排列
+	kept := make(map[string]int)
+	for _, snapshot := range snapshots {
+		kept[snapshot.Reason]++
+		if kept[snapshot.Reason] <= m.retention {
+			continue
+		}
+		if err := os.Remove(filepath.Join(m.dir, snapshot.ID+snapshotExt)); err != nil {
+			log.Printf("删除旧快照失败: %s - %v", snapshot.ID, err)
+		}
+	}
+}
+
+// writeSnapshotArchive 将 dataDir 中的文件与 uploads 目录写入 tar.gz 归档。
+// 归档中包含用户数据，只允许属主读写
+func writeSnapshotArchive(archivePath, dataDir, uploadsPath string) error {
+	file, err := os.OpenFile(archivePath, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz := gzip.NewWriter(file)
+	tw := tar.NewWriter(gz)
+
+	if err := addTreeToArchive(tw, dataDir, ""); err != nil {
+		return err
+	}
+	if _, err := os.Stat(uploadsPath); err == nil {
+		if err := addTreeToArchive(tw, uploadsPath, uploadsDir); err != nil {
+			return err
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	if err := file.Sync(); err != nil {
+		return err
+	}
+	return file.Close()
+}
+
+// addTreeToArchive 将 root 下的普通文件与目录以 prefix 为前缀写入归档
+func addTreeToArchive(tw *tar.Writer, root, prefix string) error {
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		name := filepath.ToSlash(filepath.Join(prefix, rel))
+		if rel == "." {
+			if prefix == "" {
+				return nil
+			}
+			name = prefix
+		}
+
+		// 只归档普通文件和目录，忽略符号链接等特殊文件
+		if !info.Mode().IsRegular() && !info.IsDir() {
+			return nil
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = name
+		if info.IsDir() {
+			header.Name += "/"
+		}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		_, err = io.Copy(tw, f)
+		return err
+	})
+}
+
+// extractSnapshotArchive 解压快照归档到 dir，拒绝指向 dir 之外的条目
+func extractSnapshotArchive(archivePath, dir string) error {
+	file, err := os.Open(archivePath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		name := filepath.Clean(filepath.FromSlash(header.Name))
+		if filepath.IsAbs(name) || name == ".." || strings.HasPrefix(name, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("归档中包含非法路径: %s", header.Name)
+		}
+		target := filepath.Join(dir, name)
+
+		switch header.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(target, 0755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+				return err
+			}
+			out, err := os.OpenFile(target, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0600)
+			if err != nil {
+				return err
+			}
+			if _, err := io.Copy(out, tr); err != nil {
+				out.Close()
+				return err
+			}
+			if err := out.Close(); err != nil {
+				return err
+			}
+		}
+	}
+}
@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"navdesk/models"
+)
+
+func TestSnapshotPruneKeepsRetentionPerReason(t *testing.T) {
+	store := NewJSONStore(t.TempDir())
+	manager := NewSnapshotManager(store, 2)
+	if err := os.MkdirAll(manager.dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// 先有 3 个定时快照，再连续删除 5 个分类
+	for i := 0; i < 3; i++ {
+		id := fmt.Sprintf("20260101-00000%d.000-%s", i, SnapshotScheduled)
+		if err := os.WriteFile(filepath.Join(manager.dir, id+snapshotExt), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for i := 0; i < 5; i++ {
+		id := fmt.Sprintf("20260102-00000%d.000-%s", i, SnapshotDeleteCategory)
+		if err := os.WriteFile(filepath.Join(manager.dir, id+snapshotExt), nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	manager.prune()
+
+	snapshots, err := manager.List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	counts := make(map[string]int)
+	for _, snapshot := range snapshots {
+		counts[snapshot.Reason]++
+	}
+	if counts[SnapshotScheduled] != 2 || counts[SnapshotDeleteCategory] != 2 {
+		t.Fatalf("保留的快照数量 %v，want 每种原因 2 个", counts)
+	}
+	// 保留的应是最新的快照
+	if snapshots[0].ID != "20260102-000004.000-"+SnapshotDeleteCategory {
+		t.Fatalf("最新的快照 %s 被清理", "20260102-000004.000-"+SnapshotDeleteCategory)
+	}
+}
+
+// newRestoreFixture 准备一个包含书签与上传文件的数据目录，以及内容不同的快照数据目录
+func newRestoreFixture(t *testing.T) (*JSONStore, *SnapshotManager, string) {
+	t.Helper()
+	store := NewJSONStore(t.TempDir())
+	if err := prepare(store, store); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.SaveBookmarks([]models.Bookmark{{ID: "bm_1", Name: "当前"}}); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(store.GetUploadsPath(), "common", "icon.png"), "当前")
+
+	// 快照中的数据：另一份书签与上传文件
+	source := NewJSONStore(t.TempDir())
+	if err := prepare(source, source); err != nil {
+		t.Fatal(err)
+	}
+	if err := source.SaveBookmarks([]models.Bookmark{{ID: "bm_1", Name: "快照"}}); err != nil {
+		t.Fatal(err)
+	}
+	writeTestFile(t, filepath.Join(source.GetUploadsPath(), "common", "icon.png"), "快照")
+
+	manager := NewSnapshotManager(store, 0)
+	if err := os.MkdirAll(manager.dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	return store, manager, source.GetDataPath()
+}
+
+func writeTestFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// writeTestSnapshot 将 dataDir 中的数据文件与 uploads 目录写成快照归档
+func writeTestSnapshot(t *testing.T, manager *SnapshotManager, dataDir string) string {
+	t.Helper()
+	exported := t.TempDir()
+	if err := NewJSONStore(dataDir).ExportData(exported); err != nil {
+		t.Fatal(err)
+	}
+	id := "20260101-000000.000-" + SnapshotManual
+	if err := writeSnapshotArchive(filepath.Join(manager.dir, id+snapshotExt), exported, filepath.Join(dataDir, uploadsDir)); err != nil {
+		t.Fatal(err)
+	}
+	return id
+}
+
+func assertRestoreState(t *testing.T, store *JSONStore, want string) {
+	t.Helper()
+	bookmarks, err := store.GetBookmarks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookmarks) != 1 || bookmarks[0].Name != want {
+		t.Fatalf("书签为 %+v，want %s", bookmarks, want)
+	}
+	icon, err := os.ReadFile(filepath.Join(store.GetUploadsPath(), "common", "icon.png"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(icon) != want {
+		t.Fatalf("上传文件为 %s，want %s", icon, want)
+	}
+	if entries, _ := os.ReadDir(filepath.Join(store.GetDataPath(), journalDir)); len(entries) != 0 {
+		t.Fatalf("事务日志未清理: %v", entries)
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	store, manager, snapshotData := newRestoreFixture(t)
+	id := writeTestSnapshot(t, manager, snapshotData)
+
+	if err := manager.Restore(id); err != nil {
+		t.Fatal(err)
+	}
+	assertRestoreState(t, store, "快照")
+}
+
+func TestSnapshotRestoreFailureKeepsData(t *testing.T) {
+	store, manager, snapshotData := newRestoreFixture(t)
+	// 数据版本高于程序支持的版本：数据文件写入后迁移失败
+	writeTestFile(t, filepath.Join(snapshotData, metaFile), `{"schemaVersion": 99}`)
+	id := writeTestSnapshot(t, manager, snapshotData)
+
+	if err := manager.Restore(id); err == nil {
+		t.Fatal("Restore 未返回错误")
+	}
+	assertRestoreState(t, store, "当前")
+
+	version, err := store.schemaVersion()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != SchemaVersion {
+		t.Fatalf("数据版本为 %d，want %d", version, SchemaVersion)
+	}
+}
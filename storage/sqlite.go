@@ -0,0 +1,462 @@
+package storage
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"navdesk/models"
+
+	_ "modernc.org/sqlite" // 纯 Go 实现的 SQLite 驱动，无需 cgo
+)
+
+// sqliteSchema 数据表结构。记录以 JSON 形式保存在 data 列中，
+// 模型新增字段时无需修改表结构
+const sqliteSchema = `
+CREATE TABLE IF NOT EXISTS users (
+	username TEXT PRIMARY KEY,
+	data     TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS categories (
+	id   TEXT PRIMARY KEY,
+	sort INTEGER NOT NULL DEFAULT 0,
+	data TEXT NOT NULL
+);
+CREATE TABLE IF NOT EXISTS bookmarks (
+	id       TEXT PRIMARY KEY,
+	category TEXT NOT NULL DEFAULT '',
+	sort     INTEGER NOT NULL DEFAULT 0,
+	data     TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_bookmarks_category ON bookmarks(category);
+CREATE TABLE IF NOT EXISTS documents (
+	name TEXT PRIMARY KEY,
+	data TEXT NOT NULL
+);
+`
+
+// 保存在 documents 表中的文档名称
+const (
+	docSettings  = "settings"
+	docSecretKey = "secretKey"
+)
+
+// SQLiteStore 基于 SQLite 的存储实现
+type SQLiteStore struct {
+	*writeLock
+	dataPath string
+	db       *sql.DB
+}
+
+// NewSQLiteStore 打开（或创建）数据目录下的 SQLite 数据库。
+// 数据库为新建时，会从现有的 JSON 文件导入数据
+func NewSQLiteStore(dataPath string) (*SQLiteStore, error) {
+	dbPath := filepath.Join(dataPath, sqliteFile)
+	_, statErr := os.Stat(dbPath)
+	isNew := os.IsNotExist(statErr)
+
+	db, err := sql.Open("sqlite", dbPath+"?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_pragma=foreign_keys(1)")
+	if err != nil {
+		return nil, err
+	}
+	// SQLite 同一时间只允许一个写连接，统一串行化避免 SQLITE_BUSY
+	db.SetMaxOpenConns(1)
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &SQLiteStore{
+		writeLock: newWriteLock(dataPath),
+		dataPath:  dataPath,
+		db:        db,
+	}
+
+	if isNew {
+		if err := s.importFromJSON(); err != nil {
+			db.Close()
+			os.Remove(dbPath)
+			return nil, err
+		}
+	}
+
+	return s, nil
+}
+
+// importFromJSON 从 JSON 文件导入初始数据
+func (s *SQLiteStore) importFromJSON() error {
+	source := NewJSONStore(s.dataPath)
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if data, err := ioutil.ReadFile(filepath.Join(s.dataPath, usersFile)); err == nil {
+		users, err := parseUsers(data)
+		if err != nil {
+			return err
+		}
+		for key, user := range users {
+			if err := putJSON(tx, "INSERT INTO users (username, data) VALUES (?, ?)", user, key); err != nil {
+				return err
+			}
+		}
+
+		secretKey, err := parseSecretKey(data)
+		if err != nil {
+			return err
+		}
+		if secretKey != "" {
+			if err := putDocument(tx, docSecretKey, secretKey); err != nil {
+				return err
+			}
+		}
+		log.Printf("已从 %s 导入 %d 个用户", usersFile, len(users))
+	}
+
+	if categories, err := source.GetCategories(); err == nil {
+		if err := insertCategories(tx, categories); err != nil {
+			return err
+		}
+		log.Printf("已从 %s 导入 %d 个分类", categoriesFile, len(categories))
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if bookmarks, err := source.GetBookmarks(); err == nil {
+		if err := insertBookmarks(tx, bookmarks); err != nil {
+			return err
+		}
+		log.Printf("已从 %s 导入 %d 个书签", bookmarksFile, len(bookmarks))
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	// 沿用 JSON 数据的版本号，未记录版本时由迁移流程补齐
+	version, err := source.schemaVersion()
+	if err != nil {
+		return err
+	}
+	if version > 0 {
+		if err := putDocument(tx, docMeta, schemaMeta{SchemaVersion: version, UpdatedAt: time.Now()}); err != nil {
+			return err
+		}
+	}
+
+	if _, err := os.Stat(filepath.Join(s.dataPath, settingsFile)); err == nil {
+		settings, err := source.GetSettings()
+		if err != nil {
+			return err
+		}
+		if err := putDocument(tx, docSettings, settings); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// execer 同时适配 *sql.DB 与 *sql.Tx
+type execer interface {
+	Exec(query string, args ...interface{}) (sql.Result, error)
+}
+
+// putJSON 将 value 序列化为 JSON 后作为最后一个参数执行语句
+func putJSON(e execer, query string, value interface{}, args ...interface{}) error {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	_, err = e.Exec(query, append(args, string(data))...)
+	return err
+}
+
+// putDocument 写入 documents 表
+func putDocument(e execer, name string, value interface{}) error {
+	return putJSON(e, "INSERT INTO documents (name, data) VALUES (?, ?) ON CONFLICT(name) DO UPDATE SET data = excluded.data", value, name)
+}
+
+// getDocument 读取 documents 表，不存在时返回 sql.ErrNoRows
+func (s *SQLiteStore) getDocument(name string, value interface{}) error {
+	var data string
+	if err := s.db.QueryRow("SELECT data FROM documents WHERE name = ?", name).Scan(&data); err != nil {
+		return err
+	}
+	return json.Unmarshal([]byte(data), value)
+}
+
+func insertCategories(e execer, categories []models.Category) error {
+	for _, category := range categories {
+		if err := putCategory(e, category); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putCategory(e execer, category models.Category) error {
+	return putJSON(e, `INSERT INTO categories (id, sort, data) VALUES (?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET sort = excluded.sort, data = excluded.data`,
+		category, category.ID, category.Sort)
+}
+
+func insertBookmarks(e execer, bookmarks []models.Bookmark) error {
+	for _, bookmark := range bookmarks {
+		if err := putBookmark(e, bookmark); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func putBookmark(e execer, bookmark models.Bookmark) error {
+	return putJSON(e, `INSERT INTO bookmarks (id, category, sort, data) VALUES (?, ?, ?, ?)
+		ON CONFLICT(id) DO UPDATE SET category = excluded.category, sort = excluded.sort, data = excluded.data`,
+		bookmark, bookmark.ID, bookmark.Category, bookmark.Sort)
+}
+
+// GetUsers 获取用户数据
+func (s *SQLiteStore) GetUsers() (map[string]models.User, error) {
+	rows, err := s.db.Query("SELECT username, data FROM users")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	users := make(map[string]models.User)
+	for rows.Next() {
+		var key, data string
+		if err := rows.Scan(&key, &data); err != nil {
+			return nil, err
+		}
+		var user models.User
+		if err := json.Unmarshal([]byte(data), &user); err != nil {
+			return nil, err
+		}
+		users[key] = user
+	}
+
+	return users, rows.Err()
+}
+
+// SaveUsers 整体替换用户数据
+func (s *SQLiteStore) SaveUsers(users map[string]models.User) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM users"); err != nil {
+		return err
+	}
+	for key, user := range users {
+		if err := putJSON(tx, "INSERT INTO users (username, data) VALUES (?, ?)", user, key); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// GetSecretKey 获取会话密钥
+func (s *SQLiteStore) GetSecretKey() (string, error) {
+	var secretKey string
+	if err := s.getDocument(docSecretKey, &secretKey); err != nil {
+		if err == sql.ErrNoRows {
+			return "", nil // 如果没有找到secretKey，返回空字符串
+		}
+		return "", err
+	}
+	return secretKey, nil
+}
+
+// GetCategories 获取分类数据
+func (s *SQLiteStore) GetCategories() ([]models.Category, error) {
+	rows, err := s.db.Query("SELECT data FROM categories ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	categories := []models.Category{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var category models.Category
+		if err := json.Unmarshal([]byte(data), &category); err != nil {
+			return nil, err
+		}
+		categories = append(categories, category)
+	}
+
+	return categories, rows.Err()
+}
+
+// SaveCategories 整体替换分类数据
+func (s *SQLiteStore) SaveCategories(categories []models.Category) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM categories"); err != nil {
+		return err
+	}
+	if err := insertCategories(tx, categories); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PutCategory 新增或更新单个分类
+func (s *SQLiteStore) PutCategory(category models.Category) error {
+	return putCategory(s.db, category)
+}
+
+// DeleteCategory 删除单个分类记录
+func (s *SQLiteStore) DeleteCategory(id string) error {
+	_, err := s.db.Exec("DELETE FROM categories WHERE id = ?", id)
+	return err
+}
+
+// GetBookmarks 获取书签数据
+func (s *SQLiteStore) GetBookmarks() ([]models.Bookmark, error) {
+	rows, err := s.db.Query("SELECT data FROM bookmarks ORDER BY rowid")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bookmarks := []models.Bookmark{}
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return nil, err
+		}
+		var bookmark models.Bookmark
+		if err := json.Unmarshal([]byte(data), &bookmark); err != nil {
+			return nil, err
+		}
+		bookmarks = append(bookmarks, bookmark)
+	}
+
+	return bookmarks, rows.Err()
+}
+
+// SaveBookmarks 整体替换书签数据
+func (s *SQLiteStore) SaveBookmarks(bookmarks []models.Bookmark) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("DELETE FROM bookmarks"); err != nil {
+		return err
+	}
+	if err := insertBookmarks(tx, bookmarks); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// PutBookmark 新增或更新单个书签，只写入一行
+func (s *SQLiteStore) PutBookmark(bookmark models.Bookmark) error {
+	return putBookmark(s.db, bookmark)
+}
+
+// DeleteBookmark 删除单个书签记录
+func (s *SQLiteStore) DeleteBookmark(id string) error {
+	_, err := s.db.Exec("DELETE FROM bookmarks WHERE id = ?", id)
+	return err
+}
+
+// GetSettings 获取设置数据
+func (s *SQLiteStore) GetSettings() (models.Settings, error) {
+	var settings models.Settings
+	if err := s.getDocument(docSettings, &settings); err != nil {
+		if err == sql.ErrNoRows {
+			// 如果尚未保存过设置，返回默认设置
+			settings = defaultSettings()
+			settings.UpdatedAt = time.Now()
+			return settings, nil
+		}
+		return models.Settings{}, err
+	}
+	return settings, nil
+}
+
+// SaveSettings 保存设置数据
+func (s *SQLiteStore) SaveSettings(settings models.Settings) error {
+	settings.UpdatedAt = time.Now()
+	return putDocument(s.db, docSettings, settings)
+}
+
+// GetDataPath 获取数据目录路径
+func (s *SQLiteStore) GetDataPath() string {
+	return s.dataPath
+}
+
+// GetUploadsPath 获取上传目录路径
+func (s *SQLiteStore) GetUploadsPath() string {
+	return filepath.Join(s.dataPath, uploadsDir)
+}
+
+// Close 关闭数据库连接
+func (s *SQLiteStore) Close() error {
+	return s.db.Close()
+}
+
+// ExportData 将数据库导出为 dir 下的独立文件，用于生成快照
+func (s *SQLiteStore) ExportData(dir string) error {
+	_, err := s.db.Exec("VACUUM INTO ?", filepath.Join(dir, sqliteFile))
+	return err
+}
+
+// ImportData 用 dir 中导出的数据库替换当前数据，在单个事务中完成
+func (s *SQLiteStore) ImportData(dir string) error {
+	source := filepath.Join(dir, sqliteFile)
+	if _, err := os.Stat(source); err != nil {
+		return fmt.Errorf("未找到可导入的数据库文件: %v", err)
+	}
+
+	if _, err := s.db.Exec("ATTACH DATABASE ? AS snapshot", source); err != nil {
+		return err
+	}
+	defer s.db.Exec("DETACH DATABASE snapshot")
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, table := range []string{"users", "categories", "bookmarks", "documents"} {
+		if _, err := tx.Exec("DELETE FROM main." + table); err != nil {
+			return err
+		}
+		if _, err := tx.Exec("INSERT INTO main." + table + " SELECT * FROM snapshot." + table); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+
+	return migrate(s)
+}
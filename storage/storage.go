@@ -1,192 +1,137 @@
 package storage
 
 import (
-	"encoding/json"
-	"io/ioutil"
-	"os"
-	"path/filepath"
-	"time"
+	"fmt"
+	"strings"
 
 	"navdesk/models"
 )
 
 const (
-	dataDir        = "./data"
+	// DefaultDataDir 默认数据目录
+	DefaultDataDir = "./data"
+
 	usersFile      = "users.json"
 	categoriesFile = "categories.json"
 	bookmarksFile  = "bookmarks.json"
 	settingsFile   = "settings.json"
+	metaFile       = "meta.json"
 	uploadsDir     = "uploads"
+	backupsDir     = "backups"
+	snapshotsDir   = "snapshots"
+	sqliteFile     = "navdesk.db"
+	lockFile       = ".navdesk.lock"
 )
 
-// Storage 存储接口
-type Storage struct {
-	dataPath string
-}
-
-// NewStorage 创建存储实例
-func NewStorage() *Storage {
-	return &Storage{
-		dataPath: dataDir,
-	}
-}
+// 存储驱动名称
+const (
+	DriverJSON   = "json"
+	DriverSQLite = "sqlite"
+)
 
-// 确保目录存在（保留此函数，因为上传功能仍需要）
-func (s *Storage) ensureDirectoryExists(dir string) error {
-	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return os.MkdirAll(dir, 0755)
-	}
-	return nil
+// Store 存储接口，JSON 文件与 SQLite 均实现该接口
+type Store interface {
+	// GetUsers 获取用户数据
+	GetUsers() (map[string]models.User, error)
+	// SaveUsers 整体替换用户数据，会话密钥保持不变
+	SaveUsers(users map[string]models.User) error
+	// GetSecretKey 获取会话密钥
+	GetSecretKey() (string, error)
+
+	// GetCategories 获取全部分类
+	GetCategories() ([]models.Category, error)
+	// SaveCategories 整体保存分类列表
+	SaveCategories(categories []models.Category) error
+	// PutCategory 新增或更新单个分类
+	PutCategory(category models.Category) error
+	// DeleteCategory 删除单个分类记录
+	DeleteCategory(id string) error
+
+	// GetBookmarks 获取全部书签
+	GetBookmarks() ([]models.Bookmark, error)
+	// SaveBookmarks 整体保存书签列表
+	SaveBookmarks(bookmarks []models.Bookmark) error
+	// PutBookmark 新增或更新单个书签
+	PutBookmark(bookmark models.Bookmark) error
+	// DeleteBookmark 删除单个书签记录
+	DeleteBookmark(id string) error
+
+	// GetSettings 获取设置数据
+	GetSettings() (models.Settings, error)
+	// SaveSettings 保存设置数据
+	SaveSettings(settings models.Settings) error
+
+	// GetDataPath 获取数据目录路径
+	GetDataPath() string
+	// GetUploadsPath 获取上传目录路径
+	GetUploadsPath() string
+
+	// ExportData 将数据（不含上传文件）导出到目录，用于生成快照
+	ExportData(dir string) error
+	// ImportData 用 ExportData 导出的数据替换当前数据，调用方需持有写锁
+	ImportData(dir string) error
+
+	// Lock 获取写锁，用于包裹一次完整的"读取-修改-保存"过程
+	Lock() error
+	// Unlock 释放写锁
+	Unlock()
+
+	// Close 释放底层资源
+	Close() error
 }
 
-// GetUsers 获取用户数据
-func (s *Storage) GetUsers() (map[string]models.User, error) {
-	usersPath := filepath.Join(s.dataPath, usersFile)
-	data, err := ioutil.ReadFile(usersPath)
-	if err != nil {
-		return nil, err
-	}
-
-	var usersData map[string]interface{}
-	if err := json.Unmarshal(data, &usersData); err != nil {
-		return nil, err
+// New 根据驱动名称创建存储实例，并将数据升级到当前版本
+func New(driver, dataPath string) (Store, error) {
+	if dataPath == "" {
+		dataPath = DefaultDataDir
 	}
 
-	users := make(map[string]models.User)
-	for key, value := range usersData {
-		if key == "secretKey" {
-			continue // 跳过secretKey字段
+	switch strings.ToLower(strings.TrimSpace(driver)) {
+	case "", DriverJSON:
+		s := NewJSONStore(dataPath)
+		if err := prepare(s, s); err != nil {
+			return nil, err
 		}
-		userBytes, _ := json.Marshal(value)
-		var user models.User
-		if err := json.Unmarshal(userBytes, &user); err == nil {
-			users[key] = user
+		s.Watch(watchInterval)
+		return s, nil
+	case DriverSQLite:
+		s, err := NewSQLiteStore(dataPath)
+		if err != nil {
+			return nil, err
 		}
-	}
-
-	return users, nil
-}
-
-// GetSecretKey 获取会话密钥
-func (s *Storage) GetSecretKey() (string, error) {
-	usersPath := filepath.Join(s.dataPath, usersFile)
-	data, err := ioutil.ReadFile(usersPath)
-	if err != nil {
-		return "", err
-	}
-
-	var usersData map[string]interface{}
-	if err := json.Unmarshal(data, &usersData); err != nil {
-		return "", err
-	}
-
-	if secretKey, exists := usersData["secretKey"]; exists {
-		if keyStr, ok := secretKey.(string); ok {
-			return keyStr, nil
+		if err := prepare(s, s); err != nil {
+			s.Close()
+			return nil, err
 		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("未知的存储驱动: %s", driver)
 	}
-
-	return "", nil // 如果没有找到secretKey，返回空字符串
-}
-
-// GetCategories 获取分类数据
-func (s *Storage) GetCategories() ([]models.Category, error) {
-	categoriesPath := filepath.Join(s.dataPath, categoriesFile)
-	data, err := ioutil.ReadFile(categoriesPath)
-	if err != nil {
-		return nil, err
-	}
-
-	var categories []models.Category
-	if err := json.Unmarshal(data, &categories); err != nil {
-		return nil, err
-	}
-
-	return categories, nil
 }
 
-// SaveCategories 保存分类数据
-func (s *Storage) SaveCategories(categories []models.Category) error {
-	categoriesPath := filepath.Join(s.dataPath, categoriesFile)
-	data, err := json.MarshalIndent(categories, "", "  ")
-	if err != nil {
+// prepare 在写锁保护下回滚未完成的事务并执行数据迁移，避免多个进程同时迁移
+func prepare(store Store, target migrationTarget) error {
+	if err := store.Lock(); err != nil {
 		return err
 	}
+	defer store.Unlock()
 
-	return ioutil.WriteFile(categoriesPath, data, 0644)
-}
-
-// GetBookmarks 获取书签数据
-func (s *Storage) GetBookmarks() ([]models.Bookmark, error) {
-	bookmarksPath := filepath.Join(s.dataPath, bookmarksFile)
-	data, err := ioutil.ReadFile(bookmarksPath)
-	if err != nil {
-		return nil, err
-	}
-
-	var bookmarks []models.Bookmark
-	if err := json.Unmarshal(data, &bookmarks); err != nil {
-		return nil, err
-	}
-
-	return bookmarks, nil
-}
-
-// SaveBookmarks 保存书签数据
-func (s *Storage) SaveBookmarks(bookmarks []models.Bookmark) error {
-	bookmarksPath := filepath.Join(s.dataPath, bookmarksFile)
-	data, err := json.MarshalIndent(bookmarks, "", "  ")
-	if err != nil {
+	if err := recoverTransactions(store); err != nil {
 		return err
 	}
-
-	return ioutil.WriteFile(bookmarksPath, data, 0644)
+	return migrate(target)
 }
 
-// GetSettings 获取设置数据
-func (s *Storage) GetSettings() (models.Settings, error) {
-	settingsPath := filepath.Join(s.dataPath, settingsFile)
-	data, err := ioutil.ReadFile(settingsPath)
-	if err != nil {
-		// 如果文件不存在，返回默认设置
-		return models.Settings{
-			SiteTitle:    "极简网站导航",
-			CardWidth:    180,
-			CardHeight:   80,
-			IconWidth:    50,
-			IconHeight:   50,
-			SidebarWidth: 300,
-			Theme:        "auto",
-			UpdatedAt:    time.Now(),
-		}, nil
+// defaultSettings 默认设置
+func defaultSettings() models.Settings {
+	return models.Settings{
+		SiteTitle:    "极简网站导航",
+		CardWidth:    180,
+		CardHeight:   80,
+		IconWidth:    50,
+		IconHeight:   50,
+		SidebarWidth: 300,
+		Theme:        "auto",
+		Version:      1,
 	}
-
-	var settings models.Settings
-	if err := json.Unmarshal(data, &settings); err != nil {
-		return models.Settings{}, err
-	}
-
-	return settings, nil
-}
-
-// SaveSettings 保存设置数据
-func (s *Storage) SaveSettings(settings models.Settings) error {
-	settingsPath := filepath.Join(s.dataPath, settingsFile)
-	settings.UpdatedAt = time.Now()
-	data, err := json.MarshalIndent(settings, "", "  ")
-	if err != nil {
-		return err
-	}
-
-	return ioutil.WriteFile(settingsPath, data, 0644)
-}
-
-// GetDataPath 获取数据目录路径
-func (s *Storage) GetDataPath() string {
-	return s.dataPath
-}
-
-// GetUploadsPath 获取上传目录路径
-func (s *Storage) GetUploadsPath() string {
-	return filepath.Join(s.dataPath, uploadsDir)
 }
@@ -0,0 +1,245 @@
+package storage
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"navdesk/models"
+)
+
+const (
+	tokensFile     = "tokens.json"
+	tokensLockFile = ".tokens.lock"
+
+	// TokenPrefix API 令牌的固定前缀，便于在日志与代码仓库中识别泄露的令牌
+	TokenPrefix = "nd_"
+
+	// tokenTouchInterval 最近使用时间的最小更新间隔，避免每个请求都写文件
+	tokenTouchInterval = time.Minute
+)
+
+var (
+	// ErrTokenNotFound 令牌不存在
+	ErrTokenNotFound = errors.New("令牌不存在")
+	// ErrTokenInvalid 令牌无效或已过期
+	ErrTokenInvalid = errors.New("令牌无效或已过期")
+)
+
+// storedToken tokens.json 中的令牌记录
+type storedToken struct {
+	models.APIToken
+	Hash string `json:"hash"`
+}
+
+// Tokens 个人 API 令牌，保存在 data/tokens.json 中，不随快照备份与恢复。
+// 修改令牌时持有 tokens.json 专用的跨进程文件锁，共享数据卷的多个进程不会覆盖彼此的修改。
+// 不使用数据写锁：删除用户等请求在持有数据写锁时也会吊销令牌
+type Tokens struct {
+	path string
+	lock *writeLock
+}
+
+// NewTokens 创建 API 令牌管理器
+func NewTokens(store Store) *Tokens {
+	return &Tokens{
+		path: filepath.Join(store.GetDataPath(), tokensFile),
+		lock: newFileLock(filepath.Join(store.GetDataPath(), tokensLockFile)),
+	}
+}
+
+// List 列出令牌（最新在前），username 为空时列出所有用户的令牌
+func (t *Tokens) List(username string) ([]models.APIToken, error) {
+	records, err := t.load()
+	if err != nil {
+		return nil, err
+	}
+
+	tokens := []models.APIToken{}
+	for _, record := range records {
+		if username == "" || record.Username == username {
+			tokens = append(tokens, record.APIToken)
+		}
+	}
+	sort.Slice(tokens, func(i, j int) bool {
+		return tokens[i].CreatedAt.After(tokens[j].CreatedAt)
+	})
+	return tokens, nil
+}
+
+// Create 创建令牌，返回令牌信息与令牌明文（只在此时可见）
+func (t *Tokens) Create(username, name string, scopes []string, expiresAt *time.Time) (models.APIToken, string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return models.APIToken{}, "", err
+	}
+	raw := TokenPrefix + base64.RawURLEncoding.EncodeToString(secret)
+
+	record := storedToken{
+		APIToken: models.APIToken{
+			ID:        generateID("tok"),
+			Name:      name,
+			Username:  username,
+			Prefix:    raw[:len(TokenPrefix)+6],
+			Scopes:    scopes,
+			CreatedAt: time.Now(),
+			ExpiresAt: expiresAt,
+		},
+		Hash: hashToken(raw),
+	}
+
+	err := t.update(func(records []storedToken) ([]storedToken, error) {
+		return append(records, record), nil
+	})
+	if err != nil {
+		return models.APIToken{}, "", err
+	}
+
+	return record.APIToken, raw, nil
+}
+
+// Get 获取令牌信息
+func (t *Tokens) Get(id string) (models.APIToken, error) {
+	records, err := t.load()
+	if err != nil {
+		return models.APIToken{}, err
+	}
+	for _, record := range records {
+		if record.ID == id {
+			return record.APIToken, nil
+		}
+	}
+	return models.APIToken{}, ErrTokenNotFound
+}
+
+// Revoke 吊销令牌
+func (t *Tokens) Revoke(id string) error {
+	return t.update(func(records []storedToken) ([]storedToken, error) {
+		for i, record := range records {
+			if record.ID == id {
+				return append(records[:i], records[i+1:]...), nil
+			}
+		}
+		return nil, ErrTokenNotFound
+	})
+}
+
+// RevokeUser 吊销用户的全部令牌，返回吊销的数量
+func (t *Tokens) RevokeUser(username string) (int, error) {
+	revoked := 0
+	err := t.update(func(records []storedToken) ([]storedToken, error) {
+		kept := records[:0]
+		for _, record := range records {
+			if record.Username != username {
+				kept = append(kept, record)
+			}
+		}
+		revoked = len(records) - len(kept)
+		if revoked == 0 {
+			return nil, nil
+		}
+		return kept, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return revoked, nil
+}
+
+// Authenticate 校验令牌明文，返回对应的令牌信息并更新最近使用时间
+func (t *Tokens) Authenticate(raw string) (models.APIToken, error) {
+	if !strings.HasPrefix(raw, TokenPrefix) {
+		return models.APIToken{}, ErrTokenInvalid
+	}
+	hash := hashToken(raw)
+
+	records, err := t.load()
+	if err != nil {
+		return models.APIToken{}, err
+	}
+
+	now := time.Now()
+	for _, record := range records {
+		if subtle.ConstantTimeCompare([]byte(record.Hash), []byte(hash)) != 1 {
+			continue
+		}
+		if record.ExpiresAt != nil && now.After(*record.ExpiresAt) {
+			return models.APIToken{}, ErrTokenInvalid
+		}
+
+		if record.LastUsedAt == nil || now.Sub(*record.LastUsedAt) >= tokenTouchInterval {
+			record.LastUsedAt = &now
+			// 最近使用时间只是参考信息，写入失败不影响认证
+			t.update(func(records []storedToken) ([]storedToken, error) {
+				for i := range records {
+					// 读取后已被其他进程吊销的令牌不会重新写回
+					if records[i].ID == record.ID {
+						records[i].LastUsedAt = &now
+						return records, nil
+					}
+				}
+				return nil, nil
+			})
+		}
+		return record.APIToken, nil
+	}
+
+	return models.APIToken{}, ErrTokenInvalid
+}
+
+// update 持有文件锁读取、修改并保存令牌，apply 返回错误或 nil 时不保存
+func (t *Tokens) update(apply func([]storedToken) ([]storedToken, error)) error {
+	if err := t.lock.Lock(); err != nil {
+		return err
+	}
+	defer t.lock.Unlock()
+
+	records, err := t.load()
+	if err != nil {
+		return err
+	}
+	records, err = apply(records)
+	if err != nil || records == nil {
+		return err
+	}
+	return t.save(records)
+}
+
+func (t *Tokens) load() ([]storedToken, error) {
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []storedToken{}, nil
+		}
+		return nil, err
+	}
+
+	var records []storedToken
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, err
+	}
+	return records, nil
+}
+
+func (t *Tokens) save(records []storedToken) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(t.path, data, 0600)
+}
+
+// hashToken 计算令牌的 SHA-256。令牌本身是 256 位随机数，不需要加盐或慢哈希
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
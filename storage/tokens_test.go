@@ -0,0 +1,59 @@
+package storage
+
+import (
+	"sync"
+	"testing"
+
+	"navdesk/models"
+)
+
+func TestTokensSharedAcrossProcesses(t *testing.T) {
+	store := NewJSONStore(t.TempDir())
+	// 两个实例模拟共享同一数据目录的两个进程
+	first, second := NewTokens(store), NewTokens(store)
+
+	var raws []string
+	for i := 0; i < 10; i++ {
+		_, raw, err := first.Create("admin", "脚本", []string{models.ScopeBookmarksRead}, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		raws = append(raws, raw)
+	}
+
+	// 一个进程吊销全部令牌的同时，另一个进程使用这些令牌并更新最近使用时间；
+	// 同时两个进程都在创建新令牌
+	var wg sync.WaitGroup
+	for i, raw := range raws {
+		wg.Add(3)
+		go func(raw string) {
+			defer wg.Done()
+			second.Authenticate(raw)
+		}(raw)
+		go func() {
+			defer wg.Done()
+			if _, err := first.RevokeUser("admin"); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func(tokens *Tokens) {
+			defer wg.Done()
+			if _, _, err := tokens.Create("editor", "脚本", []string{models.ScopeBookmarksRead}, nil); err != nil {
+				t.Error(err)
+			}
+		}([]*Tokens{first, second}[i%2])
+	}
+	wg.Wait()
+
+	if tokens, err := first.List("admin"); err != nil || len(tokens) != 0 {
+		t.Fatalf("已吊销的令牌 %d 个重新出现: %v", len(tokens), err)
+	}
+	for _, raw := range raws {
+		if _, err := second.Authenticate(raw); err != ErrTokenInvalid {
+			t.Fatalf("已吊销的令牌仍可使用: %v", err)
+		}
+	}
+	if tokens, err := second.List("editor"); err != nil || len(tokens) != len(raws) {
+		t.Fatalf("创建了 %d 个令牌，保存了 %d 个: %v", len(raws), len(tokens), err)
+	}
+}
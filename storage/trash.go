@@ -0,0 +1,487 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"navdesk/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	trashDir      = "trash"
+	trashItemFile = "item.json"
+)
+
+var (
+	// ErrTrashItemNotFound 回收站条目不存在
+	ErrTrashItemNotFound = errors.New("回收站条目不存在")
+	// ErrRestoreConflict 恢复的数据与现有数据冲突
+	ErrRestoreConflict = errors.New("恢复冲突")
+)
+
+// Trash 回收站。每个条目占用 data/trash/<id>/ 目录：
+// item.json 保存被删除的记录，uploads/ 下按原相对路径保存被移走的图标文件
+type Trash struct {
+	store     Store
+	dir       string
+	retention time.Duration
+	stop      chan struct{}
+}
+
+// NewTrash 创建回收站，retentionDays 为自动清理天数（0 表示不自动清理）
+func NewTrash(store Store, retentionDays int) *Trash {
+	return &Trash{
+		store:     store,
+		dir:       filepath.Join(store.GetDataPath(), trashDir),
+		retention: time.Duration(retentionDays) * 24 * time.Hour,
+	}
+}
+
+// Schedule 定期清理过期的回收站条目
+func (t *Trash) Schedule(interval time.Duration) {
+	if t.retention <= 0 {
+		return
+	}
+	t.stop = make(chan struct{})
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			t.purgeExpiredLocked()
+
+			select {
+			case <-ticker.C:
+			case <-t.stop:
+				return
+			}
+		}
+	}()
+}
+
+// Stop 停止定期清理
+func (t *Trash) Stop() {
+	if t.stop != nil {
+		close(t.stop)
+		t.stop = nil
+	}
+}
+
+func (t *Trash) purgeExpiredLocked() {
+	if err := t.store.Lock(); err != nil {
+		log.Printf("回收站清理获取写锁失败: %v", err)
+		return
+	}
+	defer t.store.Unlock()
+
+	count, err := t.PurgeExpired()
+	if err != nil {
+		log.Printf("回收站自动清理失败: %v", err)
+		return
+	}
+	if count > 0 {
+		log.Printf("回收站自动清理完成: 已永久删除 %d 个过期条目", count)
+	}
+}
+
+// DeleteBookmark 将书签及其本地图标移入回收站，调用方需持有写锁
+func (t *Trash) DeleteBookmark(bookmark models.Bookmark, deletedBy string) (models.TrashItem, error) {
+	item := t.newItem(models.TrashTypeBookmark, bookmark.Name, deletedBy)
+	item.Bookmarks = []models.Bookmark{bookmark}
+
+	tx, err := BeginTx(t.store, TxBookmarks)
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+	defer tx.Rollback()
+
+	itemDir, err := t.prepareItemDir(tx, item.ID)
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+
+	if rel, ok := uploadRelPath(bookmark.Icon); ok {
+		if err := t.moveIntoTrash(tx, itemDir, rel); err != nil {
+			return models.TrashItem{}, err
+		}
+	}
+
+	if err := writeTrashItem(itemDir, item); err != nil {
+		return models.TrashItem{}, err
+	}
+
+	if err := t.store.DeleteBookmark(bookmark.ID); err != nil {
+		return models.TrashItem{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.TrashItem{}, err
+	}
+	return item, nil
+}
+
+// DeleteCategory 将分类、其下全部书签以及上传目录移入回收站，调用方需持有写锁
+func (t *Trash) DeleteCategory(category models.Category, deletedBy string) (models.TrashItem, error) {
+	bookmarks, err := t.store.GetBookmarks()
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+
+	var categoryBookmarks []models.Bookmark
+	var remainingBookmarks []models.Bookmark
+	for _, bookmark := range bookmarks {
+		if bookmark.Category == category.ID {
+			categoryBookmarks = append(categoryBookmarks, bookmark)
+		} else {
+			remainingBookmarks = append(remainingBookmarks, bookmark)
+		}
+	}
+
+	item := t.newItem(models.TrashTypeCategory, category.Name, deletedBy)
+	item.Category = &category
+	item.Bookmarks = categoryBookmarks
+	if item.Bookmarks == nil {
+		item.Bookmarks = []models.Bookmark{}
+	}
+
+	// 上传目录、图标文件、书签与分类记录的修改在同一个事务中完成
+	tx, err := BeginTx(t.store, TxCategories, TxBookmarks)
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+	defer tx.Rollback()
+
+	itemDir, err := t.prepareItemDir(tx, item.ID)
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+
+	// 移走分类的上传目录
+	if dir := cleanUploadRel(category.UploadDir); dir != "" {
+		if err := t.moveIntoTrash(tx, itemDir, dir); err != nil {
+			return models.TrashItem{}, err
+		}
+	}
+
+	// 移走位于其他目录中的书签图标
+	for _, bookmark := range categoryBookmarks {
+		if rel, ok := uploadRelPath(bookmark.Icon); ok {
+			if err := t.moveIntoTrash(tx, itemDir, rel); err != nil {
+				return models.TrashItem{}, err
+			}
+		}
+	}
+
+	if err := writeTrashItem(itemDir, item); err != nil {
+		return models.TrashItem{}, err
+	}
+
+	if len(categoryBookmarks) > 0 {
+		if err := t.store.SaveBookmarks(remainingBookmarks); err != nil {
+			return models.TrashItem{}, err
+		}
+	}
+
+	if err := t.store.DeleteCategory(category.ID); err != nil {
+		return models.TrashItem{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.TrashItem{}, err
+	}
+	return item, nil
+}
+
+// List 列出回收站条目，按删除时间倒序
+func (t *Trash) List() ([]models.TrashItem, error) {
+	entries, err := os.ReadDir(t.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []models.TrashItem{}, nil
+		}
+		return nil, err
+	}
+
+	items := []models.TrashItem{}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		item, err := t.Get(entry.Name())
+		if err != nil {
+			log.Printf("读取回收站条目失败: %s - %v", entry.Name(), err)
+			continue
+		}
+		items = append(items, item)
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].DeletedAt.After(items[j].DeletedAt)
+	})
+
+	return items, nil
+}
+
+// Get 读取单个回收站条目
+func (t *Trash) Get(id string) (models.TrashItem, error) {
+	itemDir, err := t.itemDir(id)
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+
+	data, err := os.ReadFile(filepath.Join(itemDir, trashItemFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return models.TrashItem{}, ErrTrashItemNotFound
+		}
+		return models.TrashItem{}, err
+	}
+
+	var item models.TrashItem
+	if err := json.Unmarshal(data, &item); err != nil {
+		return models.TrashItem{}, err
+	}
+
+	if t.retention > 0 {
+		purgeAt := item.DeletedAt.Add(t.retention)
+		item.PurgeAt = &purgeAt
+	}
+
+	return item, nil
+}
+
+// Restore 恢复回收站条目，调用方需持有写锁。
+// 恢复分类时一并恢复其书签和上传目录
+func (t *Trash) Restore(id string) (models.TrashItem, error) {
+	item, err := t.Get(id)
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+	itemDir, _ := t.itemDir(id)
+
+	categories, err := t.store.GetCategories()
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+	bookmarks, err := t.store.GetBookmarks()
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+
+	if err := checkRestoreConflicts(item, categories, bookmarks); err != nil {
+		return models.TrashItem{}, err
+	}
+
+	tx, err := BeginTx(t.store, TxCategories, TxBookmarks)
+	if err != nil {
+		return models.TrashItem{}, err
+	}
+	defer tx.Rollback()
+
+	if err := t.moveOutOfTrash(tx, itemDir); err != nil {
+		return models.TrashItem{}, err
+	}
+
+	if item.Category != nil {
+		if err := t.store.PutCategory(*item.Category); err != nil {
+			return models.TrashItem{}, err
+		}
+	}
+	if len(item.Bookmarks) > 0 {
+		if err := t.store.SaveBookmarks(append(bookmarks, item.Bookmarks...)); err != nil {
+			return models.TrashItem{}, err
+		}
+	}
+
+	// 条目目录随事务提交一并删除
+	if err := tx.Remove(itemDir); err != nil {
+		return models.TrashItem{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.TrashItem{}, err
+	}
+	return item, nil
+}
+
+// Purge 永久删除回收站条目，调用方需持有写锁
+func (t *Trash) Purge(id string) error {
+	itemDir, err := t.itemDir(id)
+	if err != nil {
+		return err
+	}
+	if _, err := os.Stat(itemDir); os.IsNotExist(err) {
+		return ErrTrashItemNotFound
+	}
+	return os.RemoveAll(itemDir)
+}
+
+// PurgeAll 清空回收站，调用方需持有写锁
+func (t *Trash) PurgeAll() (int, error) {
+	items, err := t.List()
+	if err != nil {
+		return 0, err
+	}
+	for _, item := range items {
+		if err := t.Purge(item.ID); err != nil {
+			return 0, err
+		}
+	}
+	return len(items), nil
+}
+
+// PurgeExpired 永久删除超过保留天数的条目，调用方需持有写锁
+func (t *Trash) PurgeExpired() (int, error) {
+	if t.retention <= 0 {
+		return 0, nil
+	}
+
+	items, err := t.List()
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	now := time.Now()
+	for _, item := range items {
+		if item.PurgeAt != nil && now.After(*item.PurgeAt) {
+			if err := t.Purge(item.ID); err != nil {
+				return count, err
+			}
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (t *Trash) newItem(itemType, name, deletedBy string) models.TrashItem {
+	return models.TrashItem{
+		ID:        fmt.Sprintf("trash_%d_%s", time.Now().UnixNano(), strings.ReplaceAll(uuid.New().String()[:8], "-", "")),
+		Type:      itemType,
+		Name:      name,
+		DeletedBy: deletedBy,
+		DeletedAt: time.Now(),
+	}
+}
+
+// itemDir 返回条目目录，拒绝包含路径分隔符的 ID
+func (t *Trash) itemDir(id string) (string, error) {
+	if id == "" || id != filepath.Base(id) || strings.HasPrefix(id, ".") {
+		return "", ErrTrashItemNotFound
+	}
+	return filepath.Join(t.dir, id), nil
+}
+
+// prepareItemDir 在事务中创建条目目录，回滚时删除
+func (t *Trash) prepareItemDir(tx *Tx, id string) (string, error) {
+	itemDir, err := t.itemDir(id)
+	if err != nil {
+		return "", err
+	}
+	return itemDir, tx.MkdirAll(filepath.Join(itemDir, uploadsDir))
+}
+
+// moveIntoTrash 将 uploads 下的相对路径（文件或目录）移入条目目录，不存在时忽略
+func (t *Trash) moveIntoTrash(tx *Tx, itemDir, rel string) error {
+	src := filepath.Join(t.store.GetUploadsPath(), rel)
+	if !insideUploads(t.store.GetUploadsPath(), src) {
+		log.Printf("跳过指向上传目录以外的路径: %s", rel)
+		return nil
+	}
+	dst := filepath.Join(itemDir, uploadsDir, rel)
+	return tx.Rename(src, dst)
+}
+
+// moveOutOfTrash 将条目中的文件逐个移回 uploads 对应位置
+func (t *Trash) moveOutOfTrash(tx *Tx, itemDir string) error {
+	root := filepath.Join(itemDir, uploadsDir)
+	uploadsPath := t.store.GetUploadsPath()
+
+	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(uploadsPath, rel)
+
+		if info.IsDir() {
+			return tx.MkdirAll(target)
+		}
+		if err := tx.Rename(path, target); errors.Is(err, ErrDestinationExists) {
+			return fmt.Errorf("%w: 上传目录中已存在同名文件 %s", ErrRestoreConflict, filepath.ToSlash(rel))
+		} else if err != nil {
+			return err
+		}
+		return nil
+	})
+}
+
+func writeTrashItem(itemDir string, item models.TrashItem) error {
+	data, err := json.MarshalIndent(item, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(itemDir, trashItemFile), data, 0644)
+}
+
+// checkRestoreConflicts 检查恢复的记录是否与现有数据冲突
+func checkRestoreConflicts(item models.TrashItem, categories []models.Category, bookmarks []models.Bookmark) error {
+	if item.Category != nil {
+		for _, category := range categories {
+			if category.ID == item.Category.ID {
+				return fmt.Errorf("%w: 分类已存在", ErrRestoreConflict)
+			}
+			if category.Name == item.Category.Name {
+				return fmt.Errorf("%w: 分类名称已存在", ErrRestoreConflict)
+			}
+			if category.UploadDir == item.Category.UploadDir {
+				return fmt.Errorf("%w: 上传目录已被其他分类使用", ErrRestoreConflict)
+			}
+		}
+	} else {
+		for _, restored := range item.Bookmarks {
+			if restored.Category == "all" {
+				continue
+			}
+			exists := false
+			for _, category := range categories {
+				if category.ID == restored.Category {
+					exists = true
+					break
+				}
+			}
+			if !exists {
+				return fmt.Errorf("%w: 书签所属的分类不存在，请先恢复分类", ErrRestoreConflict)
+			}
+		}
+	}
+
+	for _, restored := range item.Bookmarks {
+		for _, bookmark := range bookmarks {
+			if bookmark.ID == restored.ID {
+				return fmt.Errorf("%w: 书签已存在", ErrRestoreConflict)
+			}
+			if bookmark.Category == restored.Category && bookmark.Name == restored.Name {
+				return fmt.Errorf("%w: 该分类下已存在相同名称的书签", ErrRestoreConflict)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,377 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"navdesk/models"
+
+	"github.com/google/uuid"
+)
+
+const (
+	journalDir        = "journal"
+	journalFile       = "journal.json"
+	journalDataDir    = "data"
+	journalRemovedDir = "removed"
+)
+
+// 事务中可以修改的数据集，传给 BeginTx
+const (
+	TxCategories = categoriesFile
+	TxBookmarks  = bookmarksFile
+	// TxAllData 全部数据，通过 ExportData 备份、ImportData 恢复，用于快照恢复
+	TxAllData = "all"
+)
+
+// ErrDestinationExists 事务中移动文件时目标路径已存在
+var ErrDestinationExists = errors.New("目标路径已存在")
+
+// Tx 跨多个数据文件与 uploads 目录的事务。
+// 开始时只将本次要修改的数据集备份到 data/journal/<id>/data/，之后的文件移动与目录创建先写入日志再执行；
+// 回滚时按相反顺序撤销文件移动、删除新建的目录并写回备份的数据集。
+// 进程在提交前退出时，下次启动会根据遗留的日志自动回滚
+type Tx struct {
+	store   Store
+	dir     string
+	journal txJournal
+	done    bool
+}
+
+// txJournal 事务日志（journal.json）
+type txJournal struct {
+	ID        string    `json:"id"`
+	StartedAt time.Time `json:"startedAt"`
+	Committed bool      `json:"committed"`
+	// Datasets 事务开始时备份的数据集
+	Datasets []string `json:"datasets"`
+	Moves    []txMove `json:"moves"`
+	Created  []string `json:"created"`
+}
+
+// txMove 一次文件或目录移动，路径相对于数据目录
+type txMove struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+// BeginTx 开始事务，datasets 为事务中会修改的数据集（TxCategories、TxBookmarks、TxAllData），调用方需持有写锁。
+// 典型用法：
+//
+//	tx, err := BeginTx(store, TxBookmarks)
+//	if err != nil { ... }
+//	defer tx.Rollback()
+//	... tx.Rename(...)、store.SaveXxx(...) ...
+//	return tx.Commit()
+func BeginTx(store Store, datasets ...string) (*Tx, error) {
+	id := fmt.Sprintf("tx_%d_%s", time.Now().UnixNano(), strings.ReplaceAll(uuid.New().String()[:8], "-", ""))
+	dir := filepath.Join(store.GetDataPath(), journalDir, id)
+
+	if err := os.MkdirAll(filepath.Join(dir, journalDataDir), 0755); err != nil {
+		return nil, err
+	}
+
+	tx := &Tx{
+		store: store,
+		dir:   dir,
+		journal: txJournal{
+			ID:        id,
+			StartedAt: time.Now(),
+			Moves:     []txMove{},
+			Created:   []string{},
+			Datasets:  []string{},
+		},
+	}
+
+	for _, name := range datasets {
+		if err := backupDataset(store, filepath.Join(dir, journalDataDir), name); err != nil {
+			os.RemoveAll(dir)
+			return nil, fmt.Errorf("备份事务数据失败: %v", err)
+		}
+		tx.journal.Datasets = append(tx.journal.Datasets, name)
+	}
+	if err := tx.saveJournal(); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	return tx, nil
+}
+
+// Rename 在事务中移动文件或目录，源路径不存在时忽略。
+// 目标路径已存在时返回 ErrDestinationExists，不会覆盖（被覆盖的文件无法回滚）。
+// 移动前先写入日志，保证中途退出时也能撤销
+func (tx *Tx) Rename(from, to string) error {
+	if _, err := os.Stat(from); os.IsNotExist(err) {
+		return nil
+	}
+
+	relFrom, err := tx.relPath(from)
+	if err != nil {
+		return err
+	}
+	relTo, err := tx.relPath(to)
+	if err != nil {
+		return err
+	}
+
+	if _, err := os.Lstat(to); err == nil {
+		return fmt.Errorf("%w: %s", ErrDestinationExists, relTo)
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+
+	if err := tx.MkdirAll(filepath.Dir(to)); err != nil {
+		return err
+	}
+
+	tx.journal.Moves = append(tx.journal.Moves, txMove{From: relFrom, To: relTo})
+	if err := tx.saveJournal(); err != nil {
+		tx.journal.Moves = tx.journal.Moves[:len(tx.journal.Moves)-1]
+		return err
+	}
+
+	return os.Rename(from, to)
+}
+
+// Remove 在事务中删除文件或目录，源路径不存在时忽略。
+// 实际是移入事务日志目录，提交时才真正删除
+func (tx *Tx) Remove(path string) error {
+	target := filepath.Join(tx.dir, journalRemovedDir, fmt.Sprintf("%d", len(tx.journal.Moves)))
+	return tx.Rename(path, target)
+}
+
+// MkdirAll 在事务中创建目录，回滚时删除本次新建的目录及其内容
+func (tx *Tx) MkdirAll(path string) error {
+	// 找到最上层尚不存在的目录，回滚时只需删除它
+	created := ""
+	for dir := filepath.Clean(path); ; dir = filepath.Dir(dir) {
+		if _, err := os.Stat(dir); err == nil {
+			break
+		} else if !os.IsNotExist(err) {
+			return err
+		}
+		created = dir
+		if filepath.Dir(dir) == dir {
+			break
+		}
+	}
+	if created == "" {
+		return nil
+	}
+
+	rel, err := tx.relPath(created)
+	if err != nil {
+		return err
+	}
+	tx.journal.Created = append(tx.journal.Created, rel)
+	if err := tx.saveJournal(); err != nil {
+		tx.journal.Created = tx.journal.Created[:len(tx.journal.Created)-1]
+		return err
+	}
+
+	return os.MkdirAll(path, 0755)
+}
+
+// Commit 提交事务并清理日志
+func (tx *Tx) Commit() error {
+	if tx.done {
+		return fmt.Errorf("事务已结束")
+	}
+
+	tx.journal.Committed = true
+	if err := tx.saveJournal(); err != nil {
+		return err
+	}
+	tx.done = true
+
+	if err := os.RemoveAll(tx.dir); err != nil {
+		log.Printf("清理事务日志失败: %s - %v", tx.dir, err)
+	}
+	return nil
+}
+
+// Rollback 撤销事务中的全部修改。事务已提交或已回滚时不做任何事，
+// 因此可以在 BeginTx 之后直接 defer
+func (tx *Tx) Rollback() error {
+	if tx.done {
+		return nil
+	}
+	tx.done = true
+
+	if err := rollbackJournal(tx.store, tx.dir, tx.journal); err != nil {
+		log.Printf("事务回滚失败，日志保留在 %s: %v", tx.dir, err)
+		return err
+	}
+	return nil
+}
+
+// relPath 返回相对于数据目录的路径，拒绝数据目录之外的路径
+func (tx *Tx) relPath(path string) (string, error) {
+	rel, err := filepath.Rel(tx.store.GetDataPath(), path)
+	if err != nil {
+		return "", err
+	}
+	if !insideDataPath(rel) {
+		return "", fmt.Errorf("事务只能操作数据目录内的文件: %s", path)
+	}
+	return rel, nil
+}
+
+func (tx *Tx) saveJournal() error {
+	data, err := json.MarshalIndent(tx.journal, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(tx.dir, journalFile), data, 0644)
+}
+
+// rollbackJournal 按相反顺序撤销文件移动，删除新建的目录，写回事务开始时备份的数据集并删除日志目录
+func rollbackJournal(store Store, dir string, journal txJournal) error {
+	dataPath := store.GetDataPath()
+
+	for i := len(journal.Moves) - 1; i >= 0; i-- {
+		from := filepath.Join(dataPath, journal.Moves[i].From)
+		to := filepath.Join(dataPath, journal.Moves[i].To)
+
+		// 移动未执行或已撤销时跳过
+		if _, err := os.Stat(to); os.IsNotExist(err) {
+			continue
+		}
+		if err := os.MkdirAll(filepath.Dir(from), 0755); err != nil {
+			return err
+		}
+		if err := os.Rename(to, from); err != nil {
+			return err
+		}
+	}
+
+	for i := len(journal.Created) - 1; i >= 0; i-- {
+		if err := os.RemoveAll(filepath.Join(dataPath, journal.Created[i])); err != nil {
+			return err
+		}
+	}
+
+	for _, name := range journal.Datasets {
+		if err := restoreDataset(store, filepath.Join(dir, journalDataDir), name); err != nil {
+			return fmt.Errorf("恢复事务数据失败: %v", err)
+		}
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// backupDataset 将数据集的当前内容写入事务日志目录
+func backupDataset(store Store, dir, name string) error {
+	var value interface{}
+	var err error
+	switch name {
+	case TxCategories:
+		value, err = store.GetCategories()
+	case TxBookmarks:
+		value, err = store.GetBookmarks()
+	case TxAllData:
+		if err := os.MkdirAll(filepath.Join(dir, name), 0755); err != nil {
+			return err
+		}
+		return store.ExportData(filepath.Join(dir, name))
+	default:
+		return fmt.Errorf("未知的事务数据集: %s", name)
+	}
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(filepath.Join(dir, name), data, 0644)
+}
+
+// restoreDataset 用事务日志目录中备份的内容替换数据集
+func restoreDataset(store Store, dir, name string) error {
+	if name == TxAllData {
+		return store.ImportData(filepath.Join(dir, name))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+
+	switch name {
+	case TxCategories:
+		var categories []models.Category
+		if err := json.Unmarshal(data, &categories); err != nil {
+			return fmt.Errorf("%s 格式错误: %v", name, err)
+		}
+		return store.SaveCategories(categories)
+	case TxBookmarks:
+		var bookmarks []models.Bookmark
+		if err := json.Unmarshal(data, &bookmarks); err != nil {
+			return fmt.Errorf("%s 格式错误: %v", name, err)
+		}
+		return store.SaveBookmarks(bookmarks)
+	default:
+		return fmt.Errorf("未知的事务数据集: %s", name)
+	}
+}
+
+// insideDataPath 判断相对于数据目录的路径是否仍位于数据目录内
+func insideDataPath(rel string) bool {
+	return rel != "." && rel != ".." && !filepath.IsAbs(rel) && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// recoverTransactions 处理上次运行遗留的事务日志：未提交的回滚，已提交的只清理日志。
+// 调用方需持有写锁
+func recoverTransactions(store Store) error {
+	root := filepath.Join(store.GetDataPath(), journalDir)
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		dir := filepath.Join(root, entry.Name())
+
+		data, err := os.ReadFile(filepath.Join(dir, journalFile))
+		if err != nil {
+			// 日志尚未写入即退出，事务没有做任何修改
+			if os.IsNotExist(err) {
+				os.RemoveAll(dir)
+				continue
+			}
+			return err
+		}
+
+		var journal txJournal
+		if err := json.Unmarshal(data, &journal); err != nil {
+			return fmt.Errorf("事务日志 %s 格式错误: %v", entry.Name(), err)
+		}
+
+		if journal.Committed {
+			os.RemoveAll(dir)
+			continue
+		}
+
+		log.Printf("发现未完成的事务 %s（开始于 %s），正在回滚", journal.ID, journal.StartedAt.Format(time.RFC3339))
+		if err := rollbackJournal(store, dir, journal); err != nil {
+			return fmt.Errorf("回滚未完成的事务 %s 失败: %v", journal.ID, err)
+		}
+	}
+
+	return nil
+}
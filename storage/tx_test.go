@@ -0,0 +1,122 @@
+package storage
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"navdesk/models"
+)
+
+func TestTxRollbackRestoresTouchedDataset(t *testing.T) {
+	store := NewJSONStore(t.TempDir())
+	if err := store.SaveBookmarks([]models.Bookmark{{ID: "bm_1", Name: "旧名称"}}); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := BeginTx(store, TxBookmarks)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 只备份事务涉及的数据集
+	entries, err := os.ReadDir(filepath.Join(tx.dir, journalDataDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Name() != bookmarksFile {
+		t.Fatalf("事务备份了 %v，want 只有 %s", entries, bookmarksFile)
+	}
+
+	if err := store.PutBookmark(models.Bookmark{ID: "bm_1", Name: "新名称"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Rollback(); err != nil {
+		t.Fatal(err)
+	}
+
+	bookmarks, err := store.GetBookmarks()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(bookmarks) != 1 || bookmarks[0].Name != "旧名称" {
+		t.Fatalf("回滚后书签为 %+v", bookmarks)
+	}
+	if _, err := os.Stat(tx.dir); !os.IsNotExist(err) {
+		t.Fatalf("回滚后事务日志未删除: %v", err)
+	}
+}
+
+func TestTxRenameRefusesExistingDestination(t *testing.T) {
+	store := NewJSONStore(t.TempDir())
+	dir := filepath.Join(store.GetUploadsPath(), "common")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	from := filepath.Join(dir, "a.png")
+	to := filepath.Join(dir, "b.png")
+	if err := os.WriteFile(from, []byte("a"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(to, []byte("b"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tx, err := BeginTx(store)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer tx.Rollback()
+
+	if err := tx.Rename(from, to); !errors.Is(err, ErrDestinationExists) {
+		t.Fatalf("Rename 返回 %v，want ErrDestinationExists", err)
+	}
+	if data, err := os.ReadFile(to); err != nil || string(data) != "b" {
+		t.Fatalf("目标文件被覆盖: %q, %v", data, err)
+	}
+	if _, err := os.Stat(from); err != nil {
+		t.Fatalf("源文件被移动: %v", err)
+	}
+}
+
+func TestRecoverTransactionsRollsBackUncommitted(t *testing.T) {
+	store := NewJSONStore(t.TempDir())
+	if err := store.SaveCategories([]models.Category{{ID: "cat_1", Name: "常用"}}); err != nil {
+		t.Fatal(err)
+	}
+	dir := filepath.Join(store.GetUploadsPath(), "common")
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	from := filepath.Join(dir, "icon.png")
+	if err := os.WriteFile(from, []byte("icon"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// 模拟事务执行到一半时进程退出
+	tx, err := BeginTx(store, TxCategories)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := tx.Remove(from); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.DeleteCategory("cat_1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := recoverTransactions(store); err != nil {
+		t.Fatal(err)
+	}
+
+	categories, err := store.GetCategories()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(categories) != 1 || categories[0].ID != "cat_1" {
+		t.Fatalf("恢复后分类为 %+v", categories)
+	}
+	if _, err := os.Stat(from); err != nil {
+		t.Fatalf("删除的文件未恢复: %v", err)
+	}
+}